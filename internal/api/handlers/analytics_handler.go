@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"sales-analytics/internal/export"
+	"sales-analytics/internal/models"
+	"sales-analytics/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultAffinityLimit = 20
+
+type AnalyticsHandler struct {
+	basketService     *services.BasketService
+	pivotService      *services.PivotService
+	revenueDefinition models.RevenueDefinition
+	logger            *logrus.Logger
+}
+
+func NewAnalyticsHandler(basketService *services.BasketService, pivotService *services.PivotService, revenueDefinition models.RevenueDefinition, logger *logrus.Logger) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		basketService:     basketService,
+		pivotService:      pivotService,
+		revenueDefinition: revenueDefinition,
+		logger:            logger,
+	}
+}
+
+// GetBasketAffinity handles the market-basket analysis of products or categories bought together
+func (h *AnalyticsHandler) GetBasketAffinity(c *gin.Context) {
+	filter, err := getFilter(c, h.logger)
+	if err != nil {
+		return // Error response already handled in getFilter
+	}
+
+	opts := services.AffinityOptions{
+		Level: c.DefaultQuery("level", services.AffinityLevelProduct),
+		Sort:  c.DefaultQuery("sort", services.AffinitySortLift),
+		Limit: defaultAffinityLimit,
+	}
+
+	if !services.IsValidAffinityLevel(opts.Level) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid level '%s'. Must be one of product, category", opts.Level),
+		})
+		return
+	}
+
+	if !services.IsValidAffinitySort(opts.Sort) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid sort '%s'. Must be one of support, confidence, lift", opts.Sort),
+		})
+		return
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxPageLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid limit '%s'. Must be an integer between 1 and %d", value, maxPageLimit),
+			})
+			return
+		}
+		opts.Limit = limit
+	}
+
+	if value := c.Query("min_support"); value != "" {
+		minSupport, err := strconv.ParseFloat(value, 64)
+		if err != nil || minSupport < 0 || minSupport > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid min_support '%s'. Must be a number between 0 and 1", value),
+			})
+			return
+		}
+		opts.MinSupport = minSupport
+	}
+
+	affinity, err := h.basketService.GetBasketAffinity(filter, opts)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get basket affinity")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to calculate basket affinity",
+		})
+		return
+	}
+
+	respond(c, h.logger, affinity)
+}
+
+// GetPivot handles ad-hoc queries grouping orders by whitelisted dimensions and measures
+func (h *AnalyticsHandler) GetPivot(c *gin.Context) {
+	filter, err := getFilter(c, h.logger)
+	if err != nil {
+		return // Error response already handled in getFilter
+	}
+
+	filter.Revenue, err = getRevenueDefinition(c, h.revenueDefinition)
+	if err != nil {
+		return // Error response already handled in getRevenueDefinition
+	}
+
+	limit, offset, err := getLimitOffset(c)
+	if err != nil {
+		return // Error response already handled in getLimitOffset
+	}
+
+	opts := services.PivotOptions{
+		Dimensions: queryList(c, "dimensions"),
+		Measures:   queryList(c, "measures"),
+		Sort:       c.Query("sort"),
+		Descending: true,
+		Limit:      limit,
+		Offset:     offset,
+	}
+
+	switch order := c.DefaultQuery("order", "desc"); order {
+	case "asc":
+		opts.Descending = false
+	case "desc":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid order '%s'. Must be one of asc, desc", order),
+		})
+		return
+	}
+
+	if err := opts.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid query: %v. Dimensions must be among %s and measures among %s",
+				err, strings.Join(services.PivotDimensionNames(), ", "), strings.Join(services.PivotMeasureNames(), ", ")),
+		})
+		return
+	}
+
+	format, err := getFormat(c)
+	if err != nil {
+		return // Error response already handled in getFormat
+	}
+	if format != export.FormatJSON {
+		h.exportPivot(c, filter, opts, format)
+		return
+	}
+
+	result, err := h.pivotService.Query(filter, opts)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to run pivot query")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to run analytics query",
+		})
+		return
+	}
+
+	respond(c, h.logger, result)
+}
+
+// exportPivot streams a pivot query straight from the database into a download
+func (h *AnalyticsHandler) exportPivot(c *gin.Context, filter models.RevenueFilter, opts services.PivotOptions, format string) {
+	writeTable(c, h.logger, format, h.pivotService.Table(filter, opts))
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"strconv"
+
+	"sales-analytics/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cached runs a query through the query cache and reports the cache status and data version of
+// the result in the X-Cache and X-Data-Version headers. Downloads bypass the cache.
+func cached[T any](c *gin.Context, queryCache *services.QueryCache, key string, query func() (T, error)) (T, error) {
+	if isExport(c) {
+		c.Header("X-Cache", services.CacheBypass)
+		return query()
+	}
+
+	result, status, version, err := services.Cached(queryCache, key, query)
+	if err != nil {
+		return result, err
+	}
+
+	c.Header("X-Cache", status)
+	c.Header("X-Data-Version", strconv.FormatUint(uint64(version.Version), 10))
+	return result, nil
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sales-analytics/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag identifies a response by the data version, the normalized query and the response format,
+// so it changes whenever any of them does
+func etag(version models.DataVersion, key, format string) string {
+	sum := sha256.Sum256([]byte(key + "|" + format))
+	return fmt.Sprintf(`"%d-%s"`, version.Version, hex.EncodeToString(sum[:8]))
+}
+
+// etagMatches reports whether an If-None-Match header lists the given entity tag, using the weak
+// comparison required for conditional GET requests
+func etagMatches(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets the validators of a response computed from the data version and answers 304
+// Not Modified when the client's copy is still current. If-None-Match takes precedence over
+// If-Modified-Since, as in RFC 9110.
+func notModified(c *gin.Context, version models.DataVersion, key string) bool {
+	format, _ := negotiateFormat(c)
+	tag := etag(version, key, format)
+
+	c.Header("ETag", tag)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Vary", "Accept")
+	c.Header("X-Data-Version", strconv.FormatUint(uint64(version.Version), 10))
+	if !version.UpdatedAt.IsZero() {
+		c.Header("Last-Modified", version.UpdatedAt.UTC().Format(http.TimeFormat))
+	}
+
+	if header := c.GetHeader("If-None-Match"); header != "" {
+		if !etagMatches(header, tag) {
+			return false
+		}
+	} else if header := c.GetHeader("If-Modified-Since"); header != "" && !version.UpdatedAt.IsZero() {
+		since, err := http.ParseTime(header)
+		if err != nil || version.UpdatedAt.Truncate(time.Second).After(since) {
+			return false
+		}
+	} else {
+		return false
+	}
+
+	c.Status(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"sales-analytics/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEtagMatches(t *testing.T) {
+	const tag = `"42-abc"`
+
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{header: `"42-abc"`, want: true},
+		{header: `W/"42-abc"`, want: true},
+		{header: `"41-abc", "42-abc"`, want: true},
+		{header: ` "1-x" ,W/"42-abc" `, want: true},
+		{header: `*`, want: true},
+		{header: `"41-abc"`, want: false},
+		{header: `42-abc`, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := etagMatches(tt.header, tag); got != tt.want {
+				t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	updated := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	version := models.DataVersion{Version: 42, UpdatedAt: updated}
+	current := etag(version, "revenue:key", "json")
+	stale := etag(models.DataVersion{Version: 41}, "revenue:key", "json")
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		version models.DataVersion
+		want    bool
+	}{
+		{name: "unconditional request", version: version, want: false},
+		{name: "current entity tag", headers: map[string]string{"If-None-Match": current}, version: version, want: true},
+		{name: "stale entity tag", headers: map[string]string{"If-None-Match": stale}, version: version, want: false},
+		{name: "not modified since", headers: map[string]string{"If-Modified-Since": updated.Format(http.TimeFormat)}, version: version, want: true},
+		{name: "modified since", headers: map[string]string{"If-Modified-Since": updated.Add(-time.Second).Format(http.TimeFormat)}, version: version, want: false},
+		{name: "unreadable date", headers: map[string]string{"If-Modified-Since": "yesterday"}, version: version, want: false},
+		{name: "date without a data version", headers: map[string]string{"If-Modified-Since": updated.Format(http.TimeFormat)}, want: false},
+		{
+			name:    "stale entity tag takes precedence over date",
+			headers: map[string]string{"If-None-Match": stale, "If-Modified-Since": updated.Format(http.TimeFormat)},
+			version: version, want: false,
+		},
+		{
+			name:    "current entity tag takes precedence over date",
+			headers: map[string]string{"If-None-Match": current, "If-Modified-Since": updated.Add(-time.Hour).Format(http.TimeFormat)},
+			version: version, want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/revenue", nil)
+			for name, value := range tt.headers {
+				c.Request.Header.Set(name, value)
+			}
+
+			got := notModified(c, tt.version, "revenue:key")
+			if got != tt.want {
+				t.Errorf("notModified = %v, want %v", got, tt.want)
+			}
+			if tag := w.Header().Get("ETag"); tag != etag(tt.version, "revenue:key", "json") {
+				t.Errorf("ETag = %s, want the tag of version %d", tag, tt.version.Version)
+			}
+			if c.Writer.Status() == http.StatusNotModified != tt.want {
+				t.Errorf("status = %d with notModified %v", c.Writer.Status(), got)
+			}
+		})
+	}
+}
+
+func TestEtagVariesByFormat(t *testing.T) {
+	version := models.DataVersion{Version: 1}
+	if etag(version, "key", "json") == etag(version, "key", "csv") {
+		t.Error("JSON and CSV responses share an entity tag")
+	}
+}
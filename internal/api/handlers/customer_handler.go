@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"sales-analytics/internal/export"
+	"sales-analytics/internal/models"
+	"sales-analytics/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type CustomerHandler struct {
+	customerService     *services.CustomerAnalyticsService
+	segmentationService *services.SegmentationService
+	revenueDefinition   models.RevenueDefinition
+	logger              *logrus.Logger
+}
+
+func NewCustomerHandler(customerService *services.CustomerAnalyticsService, segmentationService *services.SegmentationService, revenueDefinition models.RevenueDefinition, logger *logrus.Logger) *CustomerHandler {
+	return &CustomerHandler{
+		customerService:     customerService,
+		segmentationService: segmentationService,
+		revenueDefinition:   revenueDefinition,
+		logger:              logger,
+	}
+}
+
+// GetTopCustomers handles the ranking of customers by revenue
+func (h *CustomerHandler) GetTopCustomers(c *gin.Context) {
+	filter, err := getFilter(c, h.logger)
+	if err != nil {
+		return // Error response already handled in getFilter
+	}
+
+	filter.Revenue, err = getRevenueDefinition(c, h.revenueDefinition)
+	if err != nil {
+		return // Error response already handled in getRevenueDefinition
+	}
+
+	// Only customers who bought something in the range are listed unless asked otherwise
+	page, err := getPage(c, false)
+	if err != nil {
+		return // Error response already handled in getPage
+	}
+
+	format, err := getFormat(c)
+	if err != nil {
+		return // Error response already handled in getFormat
+	}
+	if format != export.FormatJSON {
+		exportTable(c, h.logger, format, func() (*export.Table, error) {
+			return h.customerService.TopCustomersTable(filter, page)
+		})
+		return
+	}
+
+	customers, err := h.customerService.GetTopCustomers(filter, page)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get top customers")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to calculate top customers",
+		})
+		return
+	}
+
+	respond(c, h.logger, customers)
+}
+
+// GetCustomerSummary handles the lifetime summary of a single customer
+func (h *CustomerHandler) GetCustomerSummary(c *gin.Context) {
+	customerID := c.Param("id")
+
+	revenue, err := getRevenueDefinition(c, h.revenueDefinition)
+	if err != nil {
+		return // Error response already handled in getRevenueDefinition
+	}
+
+	summary, err := h.customerService.GetCustomerSummary(customerID, revenue)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": fmt.Sprintf("Customer '%s' not found", customerID),
+			})
+			return
+		}
+		h.logger.WithError(err).WithField("customer_id", customerID).Error("Failed to get customer summary")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to calculate customer summary",
+		})
+		return
+	}
+
+	respond(c, h.logger, summary)
+}
+
+// GetCustomerCounts handles the count of new and returning customers
+func (h *CustomerHandler) GetCustomerCounts(c *gin.Context) {
+	filter, err := getFilter(c, h.logger)
+	if err != nil {
+		return // Error response already handled in getFilter
+	}
+
+	counts, err := h.customerService.GetCustomerCounts(filter)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get customer counts")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to count customers",
+		})
+		return
+	}
+
+	respond(c, h.logger, counts)
+}
+
+// GetCohortRetention handles the retention matrix of customers grouped by first purchase month
+func (h *CustomerHandler) GetCohortRetention(c *gin.Context) {
+	filter, err := getFilter(c, h.logger)
+	if err != nil {
+		return // Error response already handled in getFilter
+	}
+
+	filter.Revenue, err = getRevenueDefinition(c, h.revenueDefinition)
+	if err != nil {
+		return // Error response already handled in getRevenueDefinition
+	}
+
+	if err := checkPeriods(c, filter, services.GranularityMonth, services.MaxCohortMonths); err != nil {
+		return // Error response already handled in checkPeriods
+	}
+
+	cohorts, err := h.customerService.GetCohortRetention(filter)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get cohort retention")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to calculate cohort retention",
+		})
+		return
+	}
+
+	respond(c, h.logger, cohorts)
+}
+
+// GetSegmentCounts handles the number of customers in each RFM segment
+func (h *CustomerHandler) GetSegmentCounts(c *gin.Context) {
+	asOf, err := getAsOf(c)
+	if err != nil {
+		return // Error response already handled in getAsOf
+	}
+
+	revenue, err := getRevenueDefinition(c, h.revenueDefinition)
+	if err != nil {
+		return // Error response already handled in getRevenueDefinition
+	}
+
+	counts, err := h.segmentationService.GetSegmentCounts(asOf, revenue)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get customer segment counts")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to calculate customer segments",
+		})
+		return
+	}
+
+	respond(c, h.logger, counts)
+}
+
+// GetSegmentMembers handles the paginated list of customers in an RFM segment
+func (h *CustomerHandler) GetSegmentMembers(c *gin.Context) {
+	segment := c.Param("segment")
+	if !h.segmentationService.IsValidSegment(segment) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("Segment '%s' not found", segment),
+		})
+		return
+	}
+
+	asOf, err := getAsOf(c)
+	if err != nil {
+		return // Error response already handled in getAsOf
+	}
+
+	revenue, err := getRevenueDefinition(c, h.revenueDefinition)
+	if err != nil {
+		return // Error response already handled in getRevenueDefinition
+	}
+
+	page, err := getPage(c, false)
+	if err != nil {
+		return // Error response already handled in getPage
+	}
+
+	format, err := getFormat(c)
+	if err != nil {
+		return // Error response already handled in getFormat
+	}
+	if format != export.FormatJSON {
+		exportTable(c, h.logger, format, func() (*export.Table, error) {
+			return h.segmentationService.SegmentMembersTable(segment, asOf, revenue, page)
+		})
+		return
+	}
+
+	members, err := h.segmentationService.GetSegmentMembers(segment, asOf, revenue, page)
+	if err != nil {
+		h.logger.WithError(err).WithField("segment", segment).Error("Failed to get customer segment members")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list customer segment members",
+		})
+		return
+	}
+
+	respond(c, h.logger, members)
+}
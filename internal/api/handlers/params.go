@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sales-analytics/internal/models"
+	"sales-analytics/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+	// maxDownloadLimit bounds the rows of a CSV, Excel or Parquet download
+	maxDownloadLimit = 100000
+	// defaultSeriesLimit is the number of groups a grouped time series returns separately
+	defaultSeriesLimit = 10
+)
+
+// getPage extracts and validates the limit, offset/cursor, sort and include_zero options of a
+// breakdown; includeZero is the default when include_zero is not given
+func getPage(c *gin.Context, includeZero bool) (models.PageRequest, error) {
+	limit, offset, err := getLimitOffset(c)
+	if err != nil {
+		return models.PageRequest{}, err
+	}
+
+	page := models.PageRequest{
+		Limit:       limit,
+		Offset:      offset,
+		Sort:        c.DefaultQuery("sort", models.SortByRevenue),
+		IncludeZero: includeZero,
+	}
+
+	switch page.Sort {
+	case models.SortByRevenue, models.SortByQuantity, models.SortByName:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid sort '%s'. Must be one of revenue, quantity, name", page.Sort),
+		})
+		return models.PageRequest{}, fmt.Errorf("invalid sort")
+	}
+
+	// Names sort alphabetically by default, amounts largest first
+	order := "desc"
+	if page.Sort == models.SortByName {
+		order = "asc"
+	}
+	switch order = c.DefaultQuery("order", order); order {
+	case "asc":
+		page.Descending = false
+	case "desc":
+		page.Descending = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid order '%s'. Must be one of asc, desc", order),
+		})
+		return models.PageRequest{}, fmt.Errorf("invalid order")
+	}
+
+	if value := c.Query("include_zero"); value != "" {
+		includeZero, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid include_zero '%s'. Must be true or false", value),
+			})
+			return models.PageRequest{}, err
+		}
+		page.IncludeZero = includeZero
+	}
+
+	return page, nil
+}
+
+// getLimitOffset extracts and validates the limit and the offset or cursor of a page. Downloads
+// return up to maxDownloadLimit rows unless a lower limit is given.
+func getLimitOffset(c *gin.Context) (int, int, error) {
+	limit, max := defaultPageLimit, maxPageLimit
+	if isExport(c) {
+		limit, max = maxDownloadLimit, maxDownloadLimit
+	}
+
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > max {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid limit '%s'. Must be an integer between 1 and %d", value, max),
+			})
+			return 0, 0, fmt.Errorf("invalid limit")
+		}
+		limit = parsed
+	}
+
+	// A cursor from a previous response takes precedence over an explicit offset
+	offset := 0
+	if cursor := c.Query("cursor"); cursor != "" {
+		parsed, err := services.DecodeCursor(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid cursor '%s'", cursor),
+			})
+			return 0, 0, err
+		}
+		offset = parsed
+	} else if value := c.Query("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid offset '%s'. Must be a non-negative integer", value),
+			})
+			return 0, 0, fmt.Errorf("invalid offset")
+		}
+		offset = parsed
+	}
+
+	return limit, offset, nil
+}
+
+// getSeriesLimit extracts and validates the number of groups a grouped time series returns
+// separately
+func getSeriesLimit(c *gin.Context) (int, error) {
+	value := c.Query("limit")
+	if value == "" {
+		return defaultSeriesLimit, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 || limit > services.MaxSeries {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid limit '%s'. Must be an integer between 1 and %d", value, services.MaxSeries),
+		})
+		return 0, fmt.Errorf("invalid limit")
+	}
+	return limit, nil
+}
+
+// checkPeriods rejects date ranges spanning more than max buckets of the given granularity
+func checkPeriods(c *gin.Context, filter models.RevenueFilter, granularity string, max int) error {
+	if periods := services.CountPeriods(filter.StartDate, filter.EndDate, granularity); periods > max {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("The date range spans %d %s buckets. At most %d are allowed, so narrow the range or use a coarser granularity",
+				periods, granularity, max),
+		})
+		return fmt.Errorf("too many periods")
+	}
+	return nil
+}
+
+// getComparison extracts and validates the optional period-over-period comparison mode
+func getComparison(c *gin.Context) (string, bool) {
+	compare := c.Query("compare")
+	if compare != "" && !services.IsValidComparison(compare) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid compare '%s'. Must be one of previous_period, previous_year", compare),
+		})
+		return "", false
+	}
+	return compare, true
+}
+
+// getFilter extracts and validates the date range and optional dimension filters from request
+func getFilter(c *gin.Context, logger *logrus.Logger) (models.RevenueFilter, error) {
+	startDateStr := c.Query("start_date")
+	endDateStr := c.Query("end_date")
+
+	// Check if dates are provided
+	if startDateStr == "" || endDateStr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Both start_date and end_date are required in format YYYY-MM-DD",
+		})
+		return models.RevenueFilter{}, fmt.Errorf("missing date parameters")
+	}
+
+	// Parse start date
+	startDate, err := time.Parse("2006-01-02", startDateStr)
+	if err != nil {
+		logger.WithError(err).WithField("start_date", startDateStr).Error("Invalid start date format")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid start date '%s'. Date must be in format YYYY-MM-DD", startDateStr),
+		})
+		return models.RevenueFilter{}, err
+	}
+
+	// Parse end date
+	endDate, err := time.Parse("2006-01-02", endDateStr)
+	if err != nil {
+		logger.WithError(err).WithField("end_date", endDateStr).Error("Invalid end date format")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid end date '%s'. Date must be in format YYYY-MM-DD", endDateStr),
+		})
+		return models.RevenueFilter{}, err
+	}
+
+	// Validate date range
+	if endDate.Before(startDate) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "End date cannot be before start date",
+		})
+		return models.RevenueFilter{}, fmt.Errorf("invalid date range")
+	}
+
+	filter := models.RevenueFilter{
+		StartDate:      startDate,
+		EndDate:        endDate,
+		Categories:     queryList(c, "category"),
+		Regions:        queryList(c, "region"),
+		ProductIDs:     queryList(c, "product_id"),
+		CustomerIDs:    queryList(c, "customer_id"),
+		PaymentMethods: queryList(c, "payment_method"),
+	}
+
+	// Parse quantity bounds
+	if filter.MinQuantity, err = getQuantity(c, "min_quantity"); err != nil {
+		return models.RevenueFilter{}, err
+	}
+	if filter.MaxQuantity, err = getQuantity(c, "max_quantity"); err != nil {
+		return models.RevenueFilter{}, err
+	}
+
+	if filter.MinQuantity != nil && filter.MaxQuantity != nil && *filter.MaxQuantity < *filter.MinQuantity {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "max_quantity cannot be less than min_quantity",
+		})
+		return models.RevenueFilter{}, fmt.Errorf("invalid quantity range")
+	}
+
+	return filter, nil
+}
+
+// getRevenueDefinition applies the optional revenue_basis, discount_type and include_shipping
+// overrides to the deployment's default revenue definition
+func getRevenueDefinition(c *gin.Context, defaults models.RevenueDefinition) (models.RevenueDefinition, error) {
+	includeShipping := defaults.IncludeShipping
+	if value := c.Query("include_shipping"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid include_shipping '%s'. Must be true or false", value),
+			})
+			return models.RevenueDefinition{}, err
+		}
+		includeShipping = parsed
+	}
+
+	definition, err := services.NewRevenueDefinition(
+		c.DefaultQuery("revenue_basis", defaults.Basis),
+		c.DefaultQuery("discount_type", defaults.DiscountType),
+		includeShipping,
+	)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid revenue definition: %v. revenue_basis must be one of gross, net and discount_type one of absolute, percentage", err),
+		})
+		return models.RevenueDefinition{}, err
+	}
+
+	return definition, nil
+}
+
+// getQuantity parses an optional non-negative quantity query parameter
+func getQuantity(c *gin.Context, param string) (*int, error) {
+	value := c.Query(param)
+	if value == "" {
+		return nil, nil
+	}
+
+	quantity, err := strconv.Atoi(value)
+	if err != nil || quantity < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid %s '%s'. Must be a non-negative integer", param, value),
+		})
+		return nil, fmt.Errorf("invalid %s", param)
+	}
+
+	return &quantity, nil
+}
+
+// getAsOf extracts the optional as_of reference date, defaulting to today
+func getAsOf(c *gin.Context) (time.Time, error) {
+	value := c.Query("as_of")
+	if value == "" {
+		year, month, day := time.Now().UTC().Date()
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC), nil
+	}
+
+	asOf, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid as_of date '%s'. Date must be in format YYYY-MM-DD", value),
+		})
+		return time.Time{}, err
+	}
+
+	return asOf, nil
+}
+
+// queryList collects a multi-valued query parameter given either repeated (?region=A&region=B)
+// or comma-separated (?region=A,B)
+func queryList(c *gin.Context, key string) []string {
+	var values []string
+	for _, raw := range c.QueryArray(key) {
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
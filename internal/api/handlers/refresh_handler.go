@@ -1,43 +1,69 @@
 package handlers
 
 import (
+	"encoding/csv"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
+	"sales-analytics/internal/models"
 	"sales-analytics/internal/services"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultJobListLimit = 20
+	maxJobListLimit     = 100
 )
 
 type RefreshHandler struct {
 	loaderService *services.LoaderService
+	logger        *logrus.Logger
 	csvFilePath   string
+	loadOptions   services.LoadOptions
 }
 
-func NewRefreshHandler(loaderService *services.LoaderService, csvFilePath string) *RefreshHandler {
+func NewRefreshHandler(loaderService *services.LoaderService, logger *logrus.Logger, csvFilePath string, loadOptions services.LoadOptions) *RefreshHandler {
 	return &RefreshHandler{
 		loaderService: loaderService,
+		logger:        logger,
 		csvFilePath:   csvFilePath,
+		loadOptions:   loadOptions,
 	}
 }
 
-// RefreshData handles the data refresh operation
+// RefreshData starts a data refresh and returns the ID of the job tracking it. Only new rows are
+// loaded unless full=true is given; reconcile, delete_missing and atomic override the configured
+// handling of changed and removed orders and whether the refresh goes through staging tables.
 func (h *RefreshHandler) RefreshData(c *gin.Context) {
-	if h.loaderService.IsLoading() {
-		c.JSON(http.StatusConflict, gin.H{
-			"status":  "error",
-			"message": "Data refresh is already in progress, please try again later",
-		})
-		return
+	opts := h.loadOptions
+	if err := getBool(c, "full", &opts.ForceFull); err != nil {
+		return // Error response already handled in getBool
+	}
+	if err := getBool(c, "reconcile", &opts.Reconcile); err != nil {
+		return // Error response already handled in getBool
+	}
+	if err := getBool(c, "delete_missing", &opts.DeleteMissing); err != nil {
+		return // Error response already handled in getBool
+	}
+	if err := getBool(c, "atomic", &opts.Atomic); err != nil {
+		return // Error response already handled in getBool
 	}
 
-	if err := h.loaderService.LoadData(h.csvFilePath); err != nil {
-		if err.Error() == "data refresh is already in progress, please try again later" {
+	job, err := h.loaderService.LoadData(h.csvFilePath, models.RefreshTriggerManual, opts)
+	if err != nil {
+		if errors.Is(err, services.ErrLoadInProgress) {
 			c.JSON(http.StatusConflict, gin.H{
 				"status":  "error",
 				"message": err.Error(),
 			})
 			return
 		}
+		h.logger.WithError(err).Error("Failed to start data refresh")
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  "error",
 			"message": err.Error(),
@@ -45,8 +71,125 @@ func (h *RefreshHandler) RefreshData(c *gin.Context) {
 		return
 	}
 
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":  "accepted",
+		"message": "Data refresh started",
+		"job_id":  job.ID,
+	})
+}
+
+// getBool overwrites value with the named boolean query parameter when it is given
+func getBool(c *gin.Context, name string, value *bool) error {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid %s '%s'. Must be true or false", name, raw),
+		})
+		return err
+	}
+	*value = parsed
+	return nil
+}
+
+// GetRefreshJob returns the current state of a single refresh job
+func (h *RefreshHandler) GetRefreshJob(c *gin.Context) {
+	job, ok := h.getJob(c)
+	if !ok {
+		return // Error response already handled in getJob
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// DownloadRejectedRows streams the rows rejected by a refresh job as a CSV file
+func (h *RefreshHandler) DownloadRejectedRows(c *gin.Context) {
+	job, ok := h.getJob(c)
+	if !ok {
+		return // Error response already handled in getJob
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=refresh-%d-rejects.csv", job.ID))
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	if err := writer.Write([]string{"line_number", "reason", "raw_row"}); err != nil {
+		return
+	}
+
+	err := h.loaderService.ForEachRejectedRow(job.ID, func(row models.RejectedRow) error {
+		return writer.Write([]string{strconv.Itoa(row.LineNumber), row.Reason, row.RawRow})
+	})
+	writer.Flush()
+	if err != nil {
+		// Headers are already sent, so the best we can do is log the truncated download
+		h.logger.WithError(err).WithField("job_id", job.ID).Error("Failed to stream rejected rows")
+	}
+}
+
+// getJob loads the refresh job referenced by the :id path parameter
+func (h *RefreshHandler) getJob(c *gin.Context) (*models.RefreshJob, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid refresh job ID '%s'", c.Param("id")),
+		})
+		return nil, false
+	}
+
+	job, err := h.loaderService.GetJob(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": fmt.Sprintf("Refresh job %d not found", id),
+			})
+			return nil, false
+		}
+		h.logger.WithError(err).WithField("job_id", id).Error("Failed to get refresh job")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get refresh job",
+		})
+		return nil, false
+	}
+
+	return job, true
+}
+
+// ListRefreshJobs returns the refresh job history, newest first
+func (h *RefreshHandler) ListRefreshJobs(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultJobListLimit)))
+	if err != nil || limit <= 0 || limit > maxJobListLimit {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("limit must be an integer between 1 and %d", maxJobListLimit),
+		})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "offset must be a non-negative integer",
+		})
+		return
+	}
+
+	jobs, total, err := h.loaderService.ListJobs(limit, offset)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list refresh jobs")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list refresh jobs",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"status":  "success",
-		"message": "Data refresh completed successfully",
+		"jobs":   jobs,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
 	})
 }
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"sales-analytics/internal/models"
+	"sales-analytics/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type ReportHandler struct {
+	reportService *services.ReportService
+	logger        *logrus.Logger
+}
+
+func NewReportHandler(reportService *services.ReportService, logger *logrus.Logger) *ReportHandler {
+	return &ReportHandler{
+		reportService: reportService,
+		logger:        logger,
+	}
+}
+
+// CreateReport queues a report for background generation and returns the ID of its job
+func (h *ReportHandler) CreateReport(c *gin.Context) {
+	var spec models.ReportSpec
+	if err := c.ShouldBindJSON(&spec); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid report spec: %v", err),
+		})
+		return
+	}
+
+	if err := h.reportService.ValidateSpec(&spec); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	job, err := h.reportService.CreateReport(spec)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create report job")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to create report job",
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":  "accepted",
+		"message": "Report generation queued",
+		"job_id":  job.ID,
+	})
+}
+
+// GetReport returns the status of a report job, with a download link once it has completed
+func (h *ReportHandler) GetReport(c *gin.Context) {
+	job, ok := h.getReport(c)
+	if !ok {
+		return // Error response already handled in getReport
+	}
+
+	if job.Status == models.ReportStatusCompleted && !reportExpired(job) {
+		job.DownloadURL = fmt.Sprintf("/api/v1/reports/%d/download", job.ID)
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// DownloadReport serves the file generated by a completed report job
+func (h *ReportHandler) DownloadReport(c *gin.Context) {
+	job, ok := h.getReport(c)
+	if !ok {
+		return // Error response already handled in getReport
+	}
+
+	// Expired files are removed by the hourly cleanup, so refuse them as soon as they expire
+	if job.Status == models.ReportStatusExpired || (job.Status == models.ReportStatusCompleted && reportExpired(job)) {
+		c.JSON(http.StatusGone, gin.H{
+			"error": fmt.Sprintf("Report %d has expired, queue it again to download it", job.ID),
+		})
+		return
+	}
+	if job.Status != models.ReportStatusCompleted {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("Report %d is %s, not completed", job.ID, job.Status),
+		})
+		return
+	}
+
+	c.FileAttachment(job.FilePath, filepath.Base(job.FilePath))
+}
+
+// reportExpired reports whether the file of a report job has passed its expiry time
+func reportExpired(job *models.ReportJob) bool {
+	return job.ExpiresAt != nil && !time.Now().Before(*job.ExpiresAt)
+}
+
+// getReport loads the report job referenced by the :id path parameter
+func (h *ReportHandler) getReport(c *gin.Context) (*models.ReportJob, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid report ID '%s'", c.Param("id")),
+		})
+		return nil, false
+	}
+
+	job, err := h.reportService.GetReport(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": fmt.Sprintf("Report %d not found", id),
+			})
+			return nil, false
+		}
+		h.logger.WithError(err).WithField("report_id", id).Error("Failed to get report job")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get report job",
+		})
+		return nil, false
+	}
+
+	return job, true
+}
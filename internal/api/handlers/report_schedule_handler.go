@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"sales-analytics/internal/models"
+	"sales-analytics/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type ReportScheduleHandler struct {
+	scheduleService *services.ReportScheduleService
+	logger          *logrus.Logger
+}
+
+func NewReportScheduleHandler(scheduleService *services.ReportScheduleService, logger *logrus.Logger) *ReportScheduleHandler {
+	return &ReportScheduleHandler{
+		scheduleService: scheduleService,
+		logger:          logger,
+	}
+}
+
+// CreateSchedule stores a report schedule and registers it with the cron
+func (h *ReportScheduleHandler) CreateSchedule(c *gin.Context) {
+	var schedule models.ReportSchedule
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid report schedule: %v", err),
+		})
+		return
+	}
+
+	if err := h.scheduleService.ValidateSchedule(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := h.scheduleService.CreateSchedule(&schedule); err != nil {
+		h.logger.WithError(err).Error("Failed to create report schedule")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create report schedule",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// ListSchedules returns every report schedule
+func (h *ReportScheduleHandler) ListSchedules(c *gin.Context) {
+	schedules, err := h.scheduleService.ListSchedules()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list report schedules")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list report schedules",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"schedules": schedules,
+	})
+}
+
+// GetSchedule returns a single report schedule
+func (h *ReportScheduleHandler) GetSchedule(c *gin.Context) {
+	schedule, ok := h.getSchedule(c)
+	if !ok {
+		return // Error response already handled in getSchedule
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// DeleteSchedule stops and removes a report schedule; its run history is kept
+func (h *ReportScheduleHandler) DeleteSchedule(c *gin.Context) {
+	id, ok := getScheduleID(c)
+	if !ok {
+		return // Error response already handled in getScheduleID
+	}
+
+	if err := h.scheduleService.DeleteSchedule(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": fmt.Sprintf("Report schedule %d not found", id),
+			})
+			return
+		}
+		h.logger.WithError(err).WithField("schedule_id", id).Error("Failed to delete report schedule")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete report schedule",
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RunSchedule starts an immediate run of a report schedule and returns the ID of the run
+func (h *ReportScheduleHandler) RunSchedule(c *gin.Context) {
+	schedule, ok := h.getSchedule(c)
+	if !ok {
+		return // Error response already handled in getSchedule
+	}
+
+	run, err := h.scheduleService.Trigger(schedule.ID, models.ReportTriggerManual)
+	if err != nil {
+		h.logger.WithError(err).WithField("schedule_id", schedule.ID).Error("Failed to start report schedule run")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to start report schedule run",
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":  "accepted",
+		"message": "Report schedule run started",
+		"run_id":  run.ID,
+	})
+}
+
+// ListRuns returns the run history of a report schedule, newest first
+func (h *ReportScheduleHandler) ListRuns(c *gin.Context) {
+	schedule, ok := h.getSchedule(c)
+	if !ok {
+		return // Error response already handled in getSchedule
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultJobListLimit)))
+	if err != nil || limit <= 0 || limit > maxJobListLimit {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("limit must be an integer between 1 and %d", maxJobListLimit),
+		})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "offset must be a non-negative integer",
+		})
+		return
+	}
+
+	runs, total, err := h.scheduleService.ListRuns(schedule.ID, limit, offset)
+	if err != nil {
+		h.logger.WithError(err).WithField("schedule_id", schedule.ID).Error("Failed to list report schedule runs")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list report schedule runs",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"runs":   runs,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// getScheduleID parses the :id path parameter
+func getScheduleID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid report schedule ID '%s'", c.Param("id")),
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// getSchedule loads the report schedule referenced by the :id path parameter
+func (h *ReportScheduleHandler) getSchedule(c *gin.Context) (*models.ReportSchedule, bool) {
+	id, ok := getScheduleID(c)
+	if !ok {
+		return nil, false
+	}
+
+	schedule, err := h.scheduleService.GetSchedule(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": fmt.Sprintf("Report schedule %d not found", id),
+			})
+			return nil, false
+		}
+		h.logger.WithError(err).WithField("schedule_id", id).Error("Failed to get report schedule")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get report schedule",
+		})
+		return nil, false
+	}
+
+	return schedule, true
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"sales-analytics/internal/export"
+	"sales-analytics/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// negotiateFormat picks the response format from the format parameter, falling back to the
+// Accept header. It returns false when neither names a supported format.
+func negotiateFormat(c *gin.Context) (string, bool) {
+	if format := c.Query("format"); format != "" {
+		return format, export.IsValidFormat(format)
+	}
+
+	contentType := c.NegotiateFormat(
+		export.ContentType(export.FormatJSON),
+		export.ContentType(export.FormatCSV),
+		export.ContentType(export.FormatXLSX),
+		export.ContentType(export.FormatParquet),
+	)
+	if contentType == "" {
+		return "", false
+	}
+	return export.FormatForContentType(contentType), true
+}
+
+// isExport reports whether the request asks for a CSV, Excel or Parquet download
+func isExport(c *gin.Context) bool {
+	format, ok := negotiateFormat(c)
+	return ok && format != export.FormatJSON
+}
+
+// getFormat extracts and validates the requested response format
+func getFormat(c *gin.Context) (string, error) {
+	format, ok := negotiateFormat(c)
+	if !ok {
+		if c.Query("format") != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid format '%s'. Must be one of json, csv, xlsx, parquet", format),
+			})
+		} else {
+			c.JSON(http.StatusNotAcceptable, gin.H{
+				"error": "None of the accepted content types is supported. Use application/json, text/csv, " +
+					export.ContentType(export.FormatXLSX) + " or " + export.ContentType(export.FormatParquet),
+			})
+		}
+		return "", fmt.Errorf("unsupported format")
+	}
+	return format, nil
+}
+
+// respond writes a successful response as JSON, or as a CSV, Excel or Parquet download when
+// the request asks for one
+func respond(c *gin.Context, logger *logrus.Logger, payload interface{}) {
+	format, err := getFormat(c)
+	if err != nil {
+		return // Error response already handled in getFormat
+	}
+	if format == export.FormatJSON {
+		c.JSON(http.StatusOK, payload)
+		return
+	}
+
+	exportable, ok := payload.(models.Exportable)
+	if !ok {
+		c.JSON(http.StatusNotAcceptable, gin.H{
+			"error": "This endpoint can only return json",
+		})
+		return
+	}
+
+	table, err := export.FromSlice(exportable.ExportRows())
+	if err != nil {
+		logger.WithError(err).Error("Failed to prepare export")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to prepare export",
+		})
+		return
+	}
+
+	writeTable(c, logger, format, table)
+}
+
+// exportTable streams a breakdown straight from the database into a download. Downloads skip the
+// query cache, as they can be far larger than any page.
+func exportTable(c *gin.Context, logger *logrus.Logger, format string, table func() (*export.Table, error)) {
+	t, err := table()
+	if err != nil {
+		logger.WithError(err).Error("Failed to prepare export")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to prepare export",
+		})
+		return
+	}
+
+	writeTable(c, logger, format, t)
+}
+
+// writeTable streams a table as a file download named after the route
+func writeTable(c *gin.Context, logger *logrus.Logger, format string, table *export.Table) {
+	c.Header("Content-Type", export.ContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", exportName(c), format))
+	c.Status(http.StatusOK)
+
+	if err := export.Write(c.Writer, format, table); err != nil {
+		// Headers are already sent, so the best we can do is log the truncated download
+		logger.WithError(err).WithField("path", c.Request.URL.Path).Error("Failed to write export")
+	}
+}
+
+// exportName derives a file name from the route, e.g. /api/v1/revenue/product becomes
+// revenue-product
+func exportName(c *gin.Context) string {
+	var parts []string
+	for _, part := range strings.Split(c.FullPath(), "/") {
+		if part == "" || part == "api" || part == "v1" || strings.HasPrefix(part, ":") {
+			continue
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return "export"
+	}
+	return strings.Join(parts, "-")
+}
@@ -3,8 +3,9 @@ package handlers
 import (
 	"fmt"
 	"net/http"
-	"time"
 
+	"sales-analytics/internal/export"
+	"sales-analytics/internal/models"
 	"sales-analytics/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -12,25 +13,63 @@ import (
 )
 
 type RevenueHandler struct {
-	revenueService *services.RevenueService
-	logger         *logrus.Logger
+	revenueService    *services.RevenueService
+	queryCache        *services.QueryCache
+	revenueDefinition models.RevenueDefinition
+	logger            *logrus.Logger
 }
 
-func NewRevenueHandler(revenueService *services.RevenueService, logger *logrus.Logger) *RevenueHandler {
+func NewRevenueHandler(revenueService *services.RevenueService, queryCache *services.QueryCache, revenueDefinition models.RevenueDefinition, logger *logrus.Logger) *RevenueHandler {
 	return &RevenueHandler{
-		revenueService: revenueService,
-		logger:         logger,
+		revenueService:    revenueService,
+		queryCache:        queryCache,
+		revenueDefinition: revenueDefinition,
+		logger:            logger,
 	}
 }
 
 // GetTotalRevenue handles the total revenue calculation
 func (h *RevenueHandler) GetTotalRevenue(c *gin.Context) {
-	startDate, endDate, err := h.getDateRange(c)
+	filter, err := getFilter(c, h.logger)
 	if err != nil {
-		return // Error response already handled in getDateRange
+		return // Error response already handled in getFilter
 	}
 
-	revenue, err := h.revenueService.GetTotalRevenue(startDate, endDate)
+	filter.Revenue, err = getRevenueDefinition(c, h.revenueDefinition)
+	if err != nil {
+		return // Error response already handled in getRevenueDefinition
+	}
+
+	compare, ok := getComparison(c)
+	if !ok {
+		return // Error response already handled in getComparison
+	}
+	if compare != "" {
+		key := services.QueryKey("revenue", filter, compare)
+		if notModified(c, h.queryCache.Version(), key) {
+			return // Not Modified response already sent in notModified
+		}
+		comparison, err := cached(c, h.queryCache, key, func() (*models.TotalRevenueComparison, error) {
+			return h.revenueService.CompareTotalRevenue(filter, compare)
+		})
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to compare total revenue")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to compare total revenue",
+			})
+			return
+		}
+		respond(c, h.logger, comparison)
+		return
+	}
+
+	key := services.QueryKey("revenue", filter)
+	if notModified(c, h.queryCache.Version(), key) {
+		return // Not Modified response already sent in notModified
+	}
+	revenue, err := cached(c, h.queryCache, key, func() (*models.RevenueResponse, error) {
+		return h.revenueService.GetTotalRevenue(filter)
+	})
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get total revenue")
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -39,17 +78,66 @@ func (h *RevenueHandler) GetTotalRevenue(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, revenue)
+	respond(c, h.logger, revenue)
 }
 
 // GetRevenueByProduct handles revenue calculation by product
 func (h *RevenueHandler) GetRevenueByProduct(c *gin.Context) {
-	startDate, endDate, err := h.getDateRange(c)
+	filter, err := getFilter(c, h.logger)
+	if err != nil {
+		return // Error response already handled in getFilter
+	}
+
+	filter.Revenue, err = getRevenueDefinition(c, h.revenueDefinition)
 	if err != nil {
-		return // Error response already handled in getDateRange
+		return // Error response already handled in getRevenueDefinition
+	}
+
+	page, err := getPage(c, true)
+	if err != nil {
+		return // Error response already handled in getPage
+	}
+
+	compare, ok := getComparison(c)
+	if !ok {
+		return // Error response already handled in getComparison
+	}
+	if compare != "" {
+		key := services.QueryKey("revenue/product", filter, page, compare)
+		if notModified(c, h.queryCache.Version(), key) {
+			return // Not Modified response already sent in notModified
+		}
+		comparison, err := cached(c, h.queryCache, key, func() (*models.RevenueComparison[models.ProductRevenueComparison], error) {
+			return h.revenueService.CompareRevenueByProduct(filter, page, compare)
+		})
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to compare revenue by product")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to compare revenue by product",
+			})
+			return
+		}
+		respond(c, h.logger, comparison)
+		return
 	}
 
-	revenue, err := h.revenueService.GetRevenueByProduct(startDate, endDate)
+	key := services.QueryKey("revenue/product", filter, page)
+	if notModified(c, h.queryCache.Version(), key) {
+		return // Not Modified response already sent in notModified
+	}
+	format, err := getFormat(c)
+	if err != nil {
+		return // Error response already handled in getFormat
+	}
+	if format != export.FormatJSON {
+		exportTable(c, h.logger, format, func() (*export.Table, error) {
+			return h.revenueService.RevenueByProductTable(filter, page)
+		})
+		return
+	}
+	revenue, err := cached(c, h.queryCache, key, func() (*models.Page[models.ProductRevenue], error) {
+		return h.revenueService.GetRevenueByProduct(filter, page)
+	})
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get revenue by product")
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -58,17 +146,66 @@ func (h *RevenueHandler) GetRevenueByProduct(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, revenue)
+	respond(c, h.logger, revenue)
 }
 
 // GetRevenueByCategory handles revenue calculation by category
 func (h *RevenueHandler) GetRevenueByCategory(c *gin.Context) {
-	startDate, endDate, err := h.getDateRange(c)
+	filter, err := getFilter(c, h.logger)
+	if err != nil {
+		return // Error response already handled in getFilter
+	}
+
+	filter.Revenue, err = getRevenueDefinition(c, h.revenueDefinition)
 	if err != nil {
-		return // Error response already handled in getDateRange
+		return // Error response already handled in getRevenueDefinition
 	}
 
-	revenue, err := h.revenueService.GetRevenueByCategory(startDate, endDate)
+	page, err := getPage(c, true)
+	if err != nil {
+		return // Error response already handled in getPage
+	}
+
+	compare, ok := getComparison(c)
+	if !ok {
+		return // Error response already handled in getComparison
+	}
+	if compare != "" {
+		key := services.QueryKey("revenue/category", filter, page, compare)
+		if notModified(c, h.queryCache.Version(), key) {
+			return // Not Modified response already sent in notModified
+		}
+		comparison, err := cached(c, h.queryCache, key, func() (*models.RevenueComparison[models.CategoryRevenueComparison], error) {
+			return h.revenueService.CompareRevenueByCategory(filter, page, compare)
+		})
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to compare revenue by category")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to compare revenue by category",
+			})
+			return
+		}
+		respond(c, h.logger, comparison)
+		return
+	}
+
+	key := services.QueryKey("revenue/category", filter, page)
+	if notModified(c, h.queryCache.Version(), key) {
+		return // Not Modified response already sent in notModified
+	}
+	format, err := getFormat(c)
+	if err != nil {
+		return // Error response already handled in getFormat
+	}
+	if format != export.FormatJSON {
+		exportTable(c, h.logger, format, func() (*export.Table, error) {
+			return h.revenueService.RevenueByCategoryTable(filter, page)
+		})
+		return
+	}
+	revenue, err := cached(c, h.queryCache, key, func() (*models.Page[models.CategoryRevenue], error) {
+		return h.revenueService.GetRevenueByCategory(filter, page)
+	})
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get revenue by category")
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -77,17 +214,66 @@ func (h *RevenueHandler) GetRevenueByCategory(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, revenue)
+	respond(c, h.logger, revenue)
 }
 
 // GetRevenueByRegion handles revenue calculation by region
 func (h *RevenueHandler) GetRevenueByRegion(c *gin.Context) {
-	startDate, endDate, err := h.getDateRange(c)
+	filter, err := getFilter(c, h.logger)
+	if err != nil {
+		return // Error response already handled in getFilter
+	}
+
+	filter.Revenue, err = getRevenueDefinition(c, h.revenueDefinition)
 	if err != nil {
-		return // Error response already handled in getDateRange
+		return // Error response already handled in getRevenueDefinition
 	}
 
-	revenue, err := h.revenueService.GetRevenueByRegion(startDate, endDate)
+	page, err := getPage(c, true)
+	if err != nil {
+		return // Error response already handled in getPage
+	}
+
+	compare, ok := getComparison(c)
+	if !ok {
+		return // Error response already handled in getComparison
+	}
+	if compare != "" {
+		key := services.QueryKey("revenue/region", filter, page, compare)
+		if notModified(c, h.queryCache.Version(), key) {
+			return // Not Modified response already sent in notModified
+		}
+		comparison, err := cached(c, h.queryCache, key, func() (*models.RevenueComparison[models.RegionRevenueComparison], error) {
+			return h.revenueService.CompareRevenueByRegion(filter, page, compare)
+		})
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to compare revenue by region")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to compare revenue by region",
+			})
+			return
+		}
+		respond(c, h.logger, comparison)
+		return
+	}
+
+	key := services.QueryKey("revenue/region", filter, page)
+	if notModified(c, h.queryCache.Version(), key) {
+		return // Not Modified response already sent in notModified
+	}
+	format, err := getFormat(c)
+	if err != nil {
+		return // Error response already handled in getFormat
+	}
+	if format != export.FormatJSON {
+		exportTable(c, h.logger, format, func() (*export.Table, error) {
+			return h.revenueService.RevenueByRegionTable(filter, page)
+		})
+		return
+	}
+	revenue, err := cached(c, h.queryCache, key, func() (*models.Page[models.RegionRevenue], error) {
+		return h.revenueService.GetRevenueByRegion(filter, page)
+	})
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get revenue by region")
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -96,49 +282,194 @@ func (h *RevenueHandler) GetRevenueByRegion(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, revenue)
+	respond(c, h.logger, revenue)
 }
 
-// getDateRange extracts and validates date range from request
-func (h *RevenueHandler) getDateRange(c *gin.Context) (time.Time, time.Time, error) {
-	startDateStr := c.Query("start_date")
-	endDateStr := c.Query("end_date")
+// GetRevenueTimeSeries handles revenue calculation bucketed by day, week, month, quarter or year
+func (h *RevenueHandler) GetRevenueTimeSeries(c *gin.Context) {
+	filter, err := getFilter(c, h.logger)
+	if err != nil {
+		return // Error response already handled in getFilter
+	}
 
-	// Check if dates are provided
-	if startDateStr == "" || endDateStr == "" {
+	filter.Revenue, err = getRevenueDefinition(c, h.revenueDefinition)
+	if err != nil {
+		return // Error response already handled in getRevenueDefinition
+	}
+
+	granularity := c.DefaultQuery("granularity", services.GranularityMonth)
+	if !services.IsValidGranularity(granularity) {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Both start_date and end_date are required in format YYYY-MM-DD",
+			"error": fmt.Sprintf("Invalid granularity '%s'. Must be one of day, week, month, quarter, year", granularity),
 		})
-		return time.Time{}, time.Time{}, fmt.Errorf("missing date parameters")
+		return
+	}
+	if err := checkPeriods(c, filter, granularity, services.MaxPeriods); err != nil {
+		return // Error response already handled in checkPeriods
 	}
 
-	// Parse start date
-	startDate, err := time.Parse("2006-01-02", startDateStr)
-	if err != nil {
-		h.logger.WithError(err).WithField("start_date", startDateStr).Error("Invalid start date format")
+	groupBy := c.Query("group_by")
+	if groupBy != "" && !services.IsValidTimeSeriesGroup(groupBy) {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Invalid start date '%s'. Date must be in format YYYY-MM-DD", startDateStr),
+			"error": fmt.Sprintf("Invalid group_by '%s'. Must be one of product, category, region", groupBy),
 		})
-		return time.Time{}, time.Time{}, err
+		return
+	}
+	limit := 0
+	if groupBy != "" {
+		limit, err = getSeriesLimit(c)
+		if err != nil {
+			return // Error response already handled in getSeriesLimit
+		}
 	}
 
-	// Parse end date
-	endDate, err := time.Parse("2006-01-02", endDateStr)
+	key := services.QueryKey("revenue/timeseries", filter, granularity, groupBy, limit)
+	if notModified(c, h.queryCache.Version(), key) {
+		return // Not Modified response already sent in notModified
+	}
+	series, err := cached(c, h.queryCache, key, func() (*models.RevenueTimeSeries, error) {
+		return h.revenueService.GetRevenueTimeSeries(filter, granularity, groupBy, limit)
+	})
 	if err != nil {
-		h.logger.WithError(err).WithField("end_date", endDateStr).Error("Invalid end date format")
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Invalid end date '%s'. Date must be in format YYYY-MM-DD", endDateStr),
+		h.logger.WithError(err).Error("Failed to get revenue time series")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to calculate revenue time series",
 		})
-		return time.Time{}, time.Time{}, err
+		return
+	}
+
+	respond(c, h.logger, series)
+}
+
+// GetRevenueDecomposition handles the split of revenue into gross sales, discounts and shipping
+func (h *RevenueHandler) GetRevenueDecomposition(c *gin.Context) {
+	filter, err := getFilter(c, h.logger)
+	if err != nil {
+		return // Error response already handled in getFilter
 	}
 
-	// Validate date range
-	if endDate.Before(startDate) {
+	filter.Revenue, err = getRevenueDefinition(c, h.revenueDefinition)
+	if err != nil {
+		return // Error response already handled in getRevenueDefinition
+	}
+
+	groupBy := c.Query("group_by")
+	if groupBy != "" && !services.IsValidDecompositionGroup(groupBy) {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "End date cannot be before start date",
+			"error": fmt.Sprintf("Invalid group_by '%s'. Must be one of product, category, region", groupBy),
+		})
+		return
+	}
+
+	var page models.PageRequest
+	if groupBy != "" {
+		page, err = getPage(c, true)
+		if err != nil {
+			return // Error response already handled in getPage
+		}
+	}
+
+	key := services.QueryKey("revenue/decomposition", filter, groupBy, page)
+	if notModified(c, h.queryCache.Version(), key) {
+		return // Not Modified response already sent in notModified
+	}
+	format, err := getFormat(c)
+	if err != nil {
+		return // Error response already handled in getFormat
+	}
+	if groupBy != "" && format != export.FormatJSON {
+		exportTable(c, h.logger, format, func() (*export.Table, error) {
+			return h.revenueService.RevenueDecompositionTable(filter, groupBy, page)
+		})
+		return
+	}
+	decomposition, err := cached(c, h.queryCache, key, func() (*models.RevenueDecomposition, error) {
+		return h.revenueService.GetRevenueDecomposition(filter, groupBy, page)
+	})
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get revenue decomposition")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to calculate revenue decomposition",
+		})
+		return
+	}
+
+	respond(c, h.logger, decomposition)
+}
+
+// GetRevenueByPaymentMethod handles revenue calculation by payment method, optionally cross-tabulated
+// by region or month
+func (h *RevenueHandler) GetRevenueByPaymentMethod(c *gin.Context) {
+	filter, err := getFilter(c, h.logger)
+	if err != nil {
+		return // Error response already handled in getFilter
+	}
+
+	filter.Revenue, err = getRevenueDefinition(c, h.revenueDefinition)
+	if err != nil {
+		return // Error response already handled in getRevenueDefinition
+	}
+
+	if by := c.Query("cross_tab"); by != "" {
+		if !services.IsValidCrossTab(by) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid cross_tab '%s'. Must be one of region, month", by),
+			})
+			return
+		}
+		if by == services.CrossTabMonth {
+			if err := checkPeriods(c, filter, services.GranularityMonth, services.MaxPeriods); err != nil {
+				return // Error response already handled in checkPeriods
+			}
+		}
+
+		key := services.QueryKey("revenue/payment-method", filter, by)
+		if notModified(c, h.queryCache.Version(), key) {
+			return // Not Modified response already sent in notModified
+		}
+		crossTab, err := cached(c, h.queryCache, key, func() (*models.PaymentMethodCrossTab, error) {
+			return h.revenueService.GetPaymentMethodCrossTab(filter, by)
 		})
-		return time.Time{}, time.Time{}, fmt.Errorf("invalid date range")
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to get payment method cross-tab")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to calculate payment method cross-tab",
+			})
+			return
+		}
+		respond(c, h.logger, crossTab)
+		return
+	}
+
+	page, err := getPage(c, true)
+	if err != nil {
+		return // Error response already handled in getPage
+	}
+
+	key := services.QueryKey("revenue/payment-method", filter, page)
+	if notModified(c, h.queryCache.Version(), key) {
+		return // Not Modified response already sent in notModified
+	}
+	format, err := getFormat(c)
+	if err != nil {
+		return // Error response already handled in getFormat
+	}
+	if format != export.FormatJSON {
+		exportTable(c, h.logger, format, func() (*export.Table, error) {
+			return h.revenueService.RevenueByPaymentMethodTable(filter, page)
+		})
+		return
+	}
+	revenue, err := cached(c, h.queryCache, key, func() (*models.Page[models.PaymentMethodRevenue], error) {
+		return h.revenueService.GetRevenueByPaymentMethod(filter, page)
+	})
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get revenue by payment method")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to calculate revenue by payment method",
+		})
+		return
 	}
 
-	return startDate, endDate, nil
+	respond(c, h.logger, revenue)
 }
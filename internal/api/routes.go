@@ -2,6 +2,7 @@ package api
 
 import (
 	"sales-analytics/internal/api/handlers"
+	"sales-analytics/internal/models"
 	"sales-analytics/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -10,27 +11,66 @@ import (
 )
 
 type Router struct {
-	refreshHandler *handlers.RefreshHandler
-	revenueHandler *handlers.RevenueHandler
+	refreshHandler   *handlers.RefreshHandler
+	revenueHandler   *handlers.RevenueHandler
+	customerHandler  *handlers.CustomerHandler
+	analyticsHandler *handlers.AnalyticsHandler
+	reportHandler    *handlers.ReportHandler
+	scheduleHandler  *handlers.ReportScheduleHandler
 }
 
-func NewRouter(loaderService *services.LoaderService, revenueService *services.RevenueService, logger *logrus.Logger, csvFilePath string) *Router {
+func NewRouter(loaderService *services.LoaderService, revenueService *services.RevenueService, queryCache *services.QueryCache, customerService *services.CustomerAnalyticsService, segmentationService *services.SegmentationService, basketService *services.BasketService, pivotService *services.PivotService, reportService *services.ReportService, scheduleService *services.ReportScheduleService, revenueDefinition models.RevenueDefinition, logger *logrus.Logger, csvFilePath string, loadOptions services.LoadOptions) *Router {
 	return &Router{
-		refreshHandler: handlers.NewRefreshHandler(loaderService, csvFilePath),
-		revenueHandler: handlers.NewRevenueHandler(revenueService, logger),
+		refreshHandler:   handlers.NewRefreshHandler(loaderService, logger, csvFilePath, loadOptions),
+		revenueHandler:   handlers.NewRevenueHandler(revenueService, queryCache, revenueDefinition, logger),
+		customerHandler:  handlers.NewCustomerHandler(customerService, segmentationService, revenueDefinition, logger),
+		analyticsHandler: handlers.NewAnalyticsHandler(basketService, pivotService, revenueDefinition, logger),
+		reportHandler:    handlers.NewReportHandler(reportService, logger),
+		scheduleHandler:  handlers.NewReportScheduleHandler(scheduleService, logger),
 	}
 }
 
 func (r *Router) SetupRoutes(router *gin.Engine) {
 	api := router.Group("/api/v1")
 	{
-		// Data refresh endpoint
+		// Data refresh endpoints
 		api.POST("/refresh", r.refreshHandler.RefreshData)
+		api.GET("/refresh", r.refreshHandler.ListRefreshJobs)
+		api.GET("/refresh/:id", r.refreshHandler.GetRefreshJob)
+		api.GET("/refresh/:id/rejects", r.refreshHandler.DownloadRejectedRows)
 
 		// Revenue endpoints
 		api.GET("/revenue", r.revenueHandler.GetTotalRevenue)
 		api.GET("/revenue/product", r.revenueHandler.GetRevenueByProduct)
 		api.GET("/revenue/category", r.revenueHandler.GetRevenueByCategory)
 		api.GET("/revenue/region", r.revenueHandler.GetRevenueByRegion)
+		api.GET("/revenue/payment-method", r.revenueHandler.GetRevenueByPaymentMethod)
+		api.GET("/revenue/timeseries", r.revenueHandler.GetRevenueTimeSeries)
+		api.GET("/revenue/decomposition", r.revenueHandler.GetRevenueDecomposition)
+
+		// Customer analytics endpoints
+		api.GET("/customers/top", r.customerHandler.GetTopCustomers)
+		api.GET("/customers/count", r.customerHandler.GetCustomerCounts)
+		api.GET("/customers/cohorts", r.customerHandler.GetCohortRetention)
+		api.GET("/customers/segments", r.customerHandler.GetSegmentCounts)
+		api.GET("/customers/segments/:segment", r.customerHandler.GetSegmentMembers)
+		api.GET("/customers/:id/summary", r.customerHandler.GetCustomerSummary)
+
+		// Analytics endpoints
+		api.GET("/analytics/basket", r.analyticsHandler.GetBasketAffinity)
+		api.GET("/analytics/query", r.analyticsHandler.GetPivot)
+
+		// Report endpoints
+		api.POST("/reports", r.reportHandler.CreateReport)
+		api.GET("/reports/:id", r.reportHandler.GetReport)
+		api.GET("/reports/:id/download", r.reportHandler.DownloadReport)
+
+		// Report schedule endpoints
+		api.POST("/reports/schedules", r.scheduleHandler.CreateSchedule)
+		api.GET("/reports/schedules", r.scheduleHandler.ListSchedules)
+		api.GET("/reports/schedules/:id", r.scheduleHandler.GetSchedule)
+		api.DELETE("/reports/schedules/:id", r.scheduleHandler.DeleteSchedule)
+		api.POST("/reports/schedules/:id/run", r.scheduleHandler.RunSchedule)
+		api.GET("/reports/schedules/:id/runs", r.scheduleHandler.ListRuns)
 	}
 }
@@ -0,0 +1,13 @@
+// Package cache provides the storage backends of the query result cache
+package cache
+
+// Backend stores encoded query results by key. Implementations must be safe for concurrent use;
+// a shared backend such as Redis can be plugged in by implementing this interface.
+type Backend interface {
+	// Get returns the value stored under key, if any
+	Get(key string) ([]byte, bool)
+	// Set stores value under key, possibly evicting other entries
+	Set(key string, value []byte)
+	// Purge removes every entry
+	Purge()
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRU is an in-process backend that keeps up to a fixed number of entries and bytes, evicting
+// the least recently used entries when full
+type LRU struct {
+	mu       sync.Mutex
+	capacity int
+	maxBytes int64
+	size     int64
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value []byte
+}
+
+// bytes is the size an entry counts for against the byte limit
+func (e *lruEntry) bytes() int64 {
+	return int64(len(e.key) + len(e.value))
+}
+
+// NewLRU returns a cache holding up to capacity entries totalling at most maxBytes bytes of keys
+// and values
+func NewLRU(capacity int, maxBytes int64) *LRU {
+	return &LRU{
+		capacity: capacity,
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value stored under key and marks it as recently used
+func (c *LRU) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*lruEntry).value, true
+}
+
+// Set stores value under key, evicting the least recently used entries when full. Values that
+// could never fit are not stored.
+func (c *LRU) Set(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+	entry := &lruEntry{key: key, value: value}
+	if entry.bytes() > c.maxBytes {
+		return
+	}
+
+	c.items[key] = c.order.PushFront(entry)
+	c.size += entry.bytes()
+	for c.order.Len() > c.capacity || c.size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove drops an entry from the cache
+func (c *LRU) remove(elem *list.Element) {
+	entry := elem.Value.(*lruEntry)
+	c.order.Remove(elem)
+	delete(c.items, entry.key)
+	c.size -= entry.bytes()
+}
+
+// Purge removes every entry
+func (c *LRU) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+	c.size = 0
+}
@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLRU(t *testing.T) {
+	// Keys are one byte, so an entry counts for one byte more than its value
+	value := func(n int) []byte { return []byte(strings.Repeat("v", n)) }
+
+	type op struct {
+		get   string
+		key   string
+		value []byte
+	}
+
+	tests := []struct {
+		name     string
+		capacity int
+		maxBytes int64
+		ops      []op
+		want     []string
+		size     int64
+	}{
+		{
+			name:     "within limits",
+			capacity: 3, maxBytes: 100,
+			ops:  []op{{key: "a", value: value(9)}, {key: "b", value: value(9)}},
+			want: []string{"a", "b"},
+			size: 20,
+		},
+		{
+			name:     "entry limit evicts least recently set",
+			capacity: 2, maxBytes: 100,
+			ops:  []op{{key: "a", value: value(1)}, {key: "b", value: value(1)}, {key: "c", value: value(1)}},
+			want: []string{"b", "c"},
+			size: 4,
+		},
+		{
+			name:     "get marks as recently used",
+			capacity: 2, maxBytes: 100,
+			ops:  []op{{key: "a", value: value(1)}, {key: "b", value: value(1)}, {get: "a"}, {key: "c", value: value(1)}},
+			want: []string{"a", "c"},
+			size: 4,
+		},
+		{
+			name:     "byte limit evicts until the new entry fits",
+			capacity: 10, maxBytes: 30,
+			ops:  []op{{key: "a", value: value(9)}, {key: "b", value: value(9)}, {key: "c", value: value(9)}, {key: "d", value: value(14)}},
+			want: []string{"c", "d"},
+			size: 25,
+		},
+		{
+			name:     "entry larger than the byte limit is not stored",
+			capacity: 10, maxBytes: 30,
+			ops:  []op{{key: "a", value: value(9)}, {key: "b", value: value(30)}},
+			want: []string{"a"},
+			size: 10,
+		},
+		{
+			name:     "replacing an entry updates the size",
+			capacity: 10, maxBytes: 30,
+			ops:  []op{{key: "a", value: value(19)}, {key: "a", value: value(4)}},
+			want: []string{"a"},
+			size: 5,
+		},
+		{
+			name:     "oversized replacement drops the old entry",
+			capacity: 10, maxBytes: 30,
+			ops:  []op{{key: "a", value: value(9)}, {key: "a", value: value(40)}},
+			size: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewLRU(tt.capacity, tt.maxBytes)
+			for _, o := range tt.ops {
+				if o.get != "" {
+					c.Get(o.get)
+					continue
+				}
+				c.Set(o.key, o.value)
+			}
+
+			var got []string
+			for _, key := range []string{"a", "b", "c", "d"} {
+				if _, ok := c.Get(key); ok {
+					got = append(got, key)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cached keys = %q, want %q", got, tt.want)
+			}
+			if c.size != tt.size {
+				t.Errorf("size = %d, want %d", c.size, tt.size)
+			}
+		})
+	}
+}
+
+func TestLRUPurge(t *testing.T) {
+	c := NewLRU(10, 100)
+	c.Set("a", []byte("value"))
+	c.Purge()
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("Get after Purge found an entry")
+	}
+	if c.size != 0 || c.order.Len() != 0 {
+		t.Errorf("size = %d with %d entries after Purge, want empty", c.size, c.order.Len())
+	}
+}
@@ -1,8 +1,12 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -18,6 +22,40 @@ type Config struct {
 	CSVPath    string
 	CronSpec   string
 	BatchSize  int
+
+	// ReconcileOrders updates changed orders on refresh; DeleteMissingOrders also soft-deletes
+	// orders absent from the CSV file. AtomicRefresh loads through staging tables so a refresh is
+	// applied all at once or not at all. Manual refreshes can override all three.
+	ReconcileOrders     bool
+	DeleteMissingOrders bool
+	AtomicRefresh       bool
+	// AtomicMaxDrop is the share of rejected rows, or of live orders and gross sales a full load
+	// deleting missing orders would remove, above which an atomic refresh refuses its data
+	AtomicMaxDrop float64
+
+	// CSVColumnMapping maps Order/Product/Customer fields to source CSV headers
+	CSVColumnMapping map[string]string
+	// RFMSegmentsFile optionally points to a JSON file of customer segment rules
+	RFMSegmentsFile string
+
+	// Default revenue definition, which requests can override
+	RevenueBasis           string
+	RevenueDiscountType    string
+	RevenueIncludeShipping bool
+
+	// Report jobs write their files to ReportDir and delete them once ReportTTL has passed
+	ReportDir     string
+	ReportWorkers int
+	ReportTTL     time.Duration
+	// ReportDropDir receives the files of report schedules delivered to a directory
+	ReportDropDir string
+
+	// CacheBackend selects the revenue query cache ("lru" or "none"); CacheSize and CacheMaxBytes
+	// bound its entries and their total size, and results over CacheMaxEntryBytes are not cached
+	CacheBackend       string
+	CacheSize          int
+	CacheMaxBytes      int64
+	CacheMaxEntryBytes int64
 }
 
 func LoadConfig() (*Config, error) {
@@ -40,6 +78,26 @@ func LoadConfig() (*Config, error) {
 		batchSize = 1000 // default batch size
 	}
 
+	reconcileOrders, err := strconv.ParseBool(os.Getenv("RECONCILE_ORDERS"))
+	if err != nil {
+		reconcileOrders = false // default to keeping orders as first loaded
+	}
+
+	deleteMissingOrders, err := strconv.ParseBool(os.Getenv("DELETE_MISSING_ORDERS"))
+	if err != nil {
+		deleteMissingOrders = false // default to keeping orders removed from the source
+	}
+
+	atomicRefresh, err := strconv.ParseBool(os.Getenv("ATOMIC_REFRESH"))
+	if err != nil {
+		atomicRefresh = false // default to committing refreshes batch by batch
+	}
+
+	atomicMaxDrop, err := strconv.ParseFloat(os.Getenv("ATOMIC_MAX_DROP"), 64)
+	if err != nil || atomicMaxDrop < 0 || atomicMaxDrop > 1 {
+		atomicMaxDrop = 0.2 // default to refusing atomic refreshes that lose a fifth of the data
+	}
+
 	// Get database credentials from OS environment variables
 	dbUser := os.Getenv("PG_DB_USER")
 	if dbUser == "" {
@@ -51,6 +109,66 @@ func LoadConfig() (*Config, error) {
 		dbPassword = "postgres" // default password if not set
 	}
 
+	columnMapping, err := loadColumnMapping()
+	if err != nil {
+		return nil, err
+	}
+
+	revenueBasis := os.Getenv("REVENUE_BASIS")
+	if revenueBasis == "" {
+		revenueBasis = "net" // default to revenue net of discounts
+	}
+
+	revenueDiscountType := os.Getenv("REVENUE_DISCOUNT_TYPE")
+	if revenueDiscountType == "" {
+		revenueDiscountType = "absolute" // default to discounts stored as amounts
+	}
+
+	revenueIncludeShipping, err := strconv.ParseBool(os.Getenv("REVENUE_INCLUDE_SHIPPING"))
+	if err != nil {
+		revenueIncludeShipping = true // default to counting shipping as revenue
+	}
+
+	reportDir := os.Getenv("REPORT_DIR")
+	if reportDir == "" {
+		reportDir = "reports" // default report directory
+	}
+
+	reportWorkers, err := strconv.Atoi(os.Getenv("REPORT_WORKERS"))
+	if err != nil || reportWorkers <= 0 {
+		reportWorkers = 2 // default number of report workers
+	}
+
+	reportTTL, err := time.ParseDuration(os.Getenv("REPORT_TTL"))
+	if err != nil || reportTTL <= 0 {
+		reportTTL = 24 * time.Hour // default report lifetime
+	}
+
+	reportDropDir := os.Getenv("REPORT_DROP_DIR")
+	if reportDropDir == "" {
+		reportDropDir = "reports/drop" // default drop directory for scheduled reports
+	}
+
+	cacheBackend := os.Getenv("CACHE_BACKEND")
+	if cacheBackend == "" {
+		cacheBackend = "lru" // default to an in-process cache
+	}
+
+	cacheSize, err := strconv.Atoi(os.Getenv("CACHE_SIZE"))
+	if err != nil || cacheSize <= 0 {
+		cacheSize = 1000 // default number of cached query results
+	}
+
+	cacheMaxBytes, err := strconv.ParseInt(os.Getenv("CACHE_MAX_BYTES"), 10, 64)
+	if err != nil || cacheMaxBytes <= 0 {
+		cacheMaxBytes = 64 << 20 // default total size of cached query results
+	}
+
+	cacheMaxEntryBytes, err := strconv.ParseInt(os.Getenv("CACHE_MAX_ENTRY_BYTES"), 10, 64)
+	if err != nil || cacheMaxEntryBytes <= 0 {
+		cacheMaxEntryBytes = 1 << 20 // default size of the largest cached query result
+	}
+
 	return &Config{
 		DBHost:     os.Getenv("DB_HOST"),
 		DBPort:     dbPort,
@@ -62,5 +180,55 @@ func LoadConfig() (*Config, error) {
 		CSVPath:    os.Getenv("CSV_FILE_PATH"),
 		CronSpec:   os.Getenv("REFRESH_CRON"),
 		BatchSize:  batchSize,
+
+		ReconcileOrders:     reconcileOrders,
+		DeleteMissingOrders: deleteMissingOrders,
+		AtomicRefresh:       atomicRefresh,
+		AtomicMaxDrop:       atomicMaxDrop,
+
+		CSVColumnMapping: columnMapping,
+		RFMSegmentsFile:  os.Getenv("RFM_SEGMENTS_FILE"),
+
+		RevenueBasis:           revenueBasis,
+		RevenueDiscountType:    revenueDiscountType,
+		RevenueIncludeShipping: revenueIncludeShipping,
+
+		ReportDir:     reportDir,
+		ReportWorkers: reportWorkers,
+		ReportTTL:     reportTTL,
+		ReportDropDir: reportDropDir,
+
+		CacheBackend:       cacheBackend,
+		CacheSize:          cacheSize,
+		CacheMaxBytes:      cacheMaxBytes,
+		CacheMaxEntryBytes: cacheMaxEntryBytes,
 	}, nil
 }
+
+// loadColumnMapping reads CSV column overrides from the JSON file named by
+// CSV_COLUMN_MAPPING_FILE, then applies the field=Header pairs in CSV_COLUMN_MAPPING on top
+func loadColumnMapping() (map[string]string, error) {
+	mapping := make(map[string]string)
+
+	if path := os.Getenv("CSV_COLUMN_MAPPING_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV column mapping file: %v", err)
+		}
+		if err := json.Unmarshal(data, &mapping); err != nil {
+			return nil, fmt.Errorf("error parsing CSV column mapping file: %v", err)
+		}
+	}
+
+	if pairs := os.Getenv("CSV_COLUMN_MAPPING"); pairs != "" {
+		for _, pair := range strings.Split(pairs, ",") {
+			field, header, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid CSV_COLUMN_MAPPING entry '%s', expected field=Header", pair)
+			}
+			mapping[strings.TrimSpace(field)] = strings.TrimSpace(header)
+		}
+	}
+
+	return mapping, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"sales-analytics/internal/api"
+	"sales-analytics/internal/cache"
 	"sales-analytics/internal/config"
 	"sales-analytics/internal/models"
 	"sales-analytics/internal/services"
@@ -17,13 +18,21 @@ import (
 
 // Container holds all the dependencies for the application
 type Container struct {
-	Config         *config.Config
-	Logger         *logrus.Logger
-	DB             *gorm.DB
-	Cron           *cron.Cron
-	LoaderService  *services.LoaderService
-	RevenueService *services.RevenueService
-	Router         *api.Router
+	Config          *config.Config
+	Logger          *logrus.Logger
+	DB              *gorm.DB
+	Cron            *cron.Cron
+	LoaderService   *services.LoaderService
+	RollupService   *services.RollupService
+	RevenueService  *services.RevenueService
+	QueryCache      *services.QueryCache
+	CustomerService *services.CustomerAnalyticsService
+	SegmentService  *services.SegmentationService
+	BasketService   *services.BasketService
+	PivotService    *services.PivotService
+	ReportService   *services.ReportService
+	ScheduleService *services.ReportScheduleService
+	Router          *api.Router
 }
 
 // NewContainer initializes a new dependency container
@@ -52,34 +61,101 @@ func NewContainer(config *config.Config) (*Container, error) {
 	// Store database connection
 	container.DB = database
 
-	// Auto-migrate the database schemas
-	if err := database.AutoMigrate(&models.Customer{}, &models.Product{}, &models.Order{}); err != nil {
-		return nil, fmt.Errorf("failed to auto-migrate database: %v", err)
+	// Migrate the database schemas
+	if err := migrate(database); err != nil {
+		return nil, err
 	}
 
 	// Store config
 	container.Config = config
 
 	// Initialize services
-	container.LoaderService = services.NewLoaderService(database, container.Logger, config.BatchSize)
-	container.RevenueService = services.NewRevenueService(database)
+	columnMapping, err := services.NewColumnMapping(config.CSVColumnMapping)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV column mapping: %v", err)
+	}
+	container.RollupService = services.NewRollupService(database, container.Logger)
+	container.LoaderService = services.NewLoaderService(database, container.Logger, config.BatchSize, columnMapping, container.RollupService)
+	container.RevenueService = services.NewRevenueService(database, container.RollupService)
+	container.CustomerService = services.NewCustomerAnalyticsService(database)
+
+	segmentRules, err := services.LoadSegmentRules(config.RFMSegmentsFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RFM segment rules: %v", err)
+	}
+	container.SegmentService = services.NewSegmentationService(database, segmentRules)
+	container.BasketService = services.NewBasketService(database)
+	container.PivotService = services.NewPivotService(database)
+
+	revenueDefinition, err := services.NewRevenueDefinition(config.RevenueBasis, config.RevenueDiscountType, config.RevenueIncludeShipping)
+	if err != nil {
+		return nil, fmt.Errorf("invalid revenue definition: %v", err)
+	}
+	container.ReportService = services.NewReportService(database, container.PivotService, container.Logger, config.ReportDir, config.ReportTTL, config.ReportWorkers, revenueDefinition)
+
+	if err := container.LoaderService.FailInterruptedJobs(); err != nil {
+		return nil, fmt.Errorf("failed to reset interrupted refresh jobs: %v", err)
+	}
+	if err := container.LoaderService.LoadDataVersion(); err != nil {
+		return nil, err
+	}
+
+	// Cached revenue results are dropped whenever a refresh finishes
+	var cacheBackend cache.Backend
+	switch config.CacheBackend {
+	case "lru":
+		cacheBackend = cache.NewLRU(config.CacheSize, config.CacheMaxBytes)
+	case "none":
+	default:
+		return nil, fmt.Errorf("unsupported cache backend '%s'", config.CacheBackend)
+	}
+	container.QueryCache = services.NewQueryCache(cacheBackend, config.CacheMaxEntryBytes, container.LoaderService.DataVersion(), container.Logger)
+	container.LoaderService.OnRefresh(container.QueryCache.Invalidate)
+
+	loadOptions := services.LoadOptions{
+		Reconcile:     config.ReconcileOrders,
+		DeleteMissing: config.DeleteMissingOrders,
+		Atomic:        config.AtomicRefresh,
+		MaxDrop:       config.AtomicMaxDrop,
+	}
 
 	// Initialize cron
 	container.Cron = cron.New()
 	if _, err := container.Cron.AddFunc(config.CronSpec, func() {
-		if err := container.LoaderService.LoadData(config.CSVPath); err != nil {
+		if _, err := container.LoaderService.LoadData(config.CSVPath, models.RefreshTriggerCron, loadOptions); err != nil {
 			container.Logger.Errorf("Error in scheduled data refresh: %v", err)
 		}
 	}); err != nil {
 		return nil, err
 	}
+	if _, err := container.Cron.AddFunc("@hourly", func() {
+		if err := container.ReportService.ExpireReports(); err != nil {
+			container.Logger.Errorf("Error expiring reports: %v", err)
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	container.ScheduleService = services.NewReportScheduleService(database, container.ReportService, container.Cron, container.Logger, config.ReportDropDir)
+	if err := container.ScheduleService.LoadSchedules(); err != nil {
+		return nil, fmt.Errorf("failed to load report schedules: %v", err)
+	}
 
 	// Initialize router
 	container.Router = api.NewRouter(
 		container.LoaderService,
 		container.RevenueService,
+		container.QueryCache,
+		container.CustomerService,
+		container.SegmentService,
+		container.BasketService,
+		container.PivotService,
+		container.ReportService,
+		container.ScheduleService,
+		revenueDefinition,
 		container.Logger,
 		config.CSVPath,
+		loadOptions,
 	)
 
 	return container, nil
@@ -87,12 +163,21 @@ func NewContainer(config *config.Config) (*Container, error) {
 
 // Start starts all the background services
 func (c *Container) Start() {
+	// Revenue queries read the orders table until the rollups have been built
+	go func() {
+		if err := c.RollupService.Refresh(); err != nil {
+			c.Logger.Errorf("Error building rollups: %v", err)
+		}
+	}()
 	c.Cron.Start()
+	c.ReportService.Start()
 }
 
 // Stop gracefully stops all services
 func (c *Container) Stop() {
-	c.Cron.Stop()
+	<-c.Cron.Stop().Done()
+	c.ScheduleService.Wait()
+	c.ReportService.Stop()
 	sqlDB, err := c.DB.DB()
 	if err != nil {
 		c.Logger.Errorf("Error getting underlying *sql.DB: %v", err)
@@ -0,0 +1,70 @@
+package container
+
+import (
+	"fmt"
+
+	"sales-analytics/internal/models"
+	"sales-analytics/internal/services"
+
+	"gorm.io/gorm"
+)
+
+// migrate brings the database schema up to date and backfills data for newly added columns.
+// Every step is idempotent so it can run on each start.
+func migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(
+		&models.Customer{},
+		&models.Product{},
+		&models.Order{},
+		&models.ProductPrice{},
+		&models.RefreshJob{},
+		&models.RejectedRow{},
+		&models.SourceFile{},
+		&models.DailyProductRevenue{},
+		&models.DailyRegionRevenue{},
+		&models.ReportJob{},
+		&models.ReportSchedule{},
+		&models.ReportScheduleRun{},
+	); err != nil {
+		return fmt.Errorf("failed to auto-migrate database: %v", err)
+	}
+
+	// Orders loaded before unit prices were captured take the current product price
+	if err := db.Exec(`
+		UPDATE orders SET unit_price = products.unit_price
+		FROM products
+		WHERE products.product_id = orders.product_id AND orders.unit_price IS NULL
+	`).Error; err != nil {
+		return fmt.Errorf("failed to backfill order unit prices: %v", err)
+	}
+
+	// Earlier loads kept a price history entry for every day of sale, so drop the entries that
+	// do not change the price
+	if err := services.CompactPriceHistory(db); err != nil {
+		return fmt.Errorf("failed to compact product price history: %v", err)
+	}
+
+	// Seed the price history of products that have none from the prices their orders sold at,
+	// keeping only the days the price changed. Days are taken in UTC like the rollups.
+	if err := db.Exec(`
+		INSERT INTO product_prices (product_id, unit_price, effective_date, created_at, updated_at)
+		SELECT product_id, unit_price, effective_date, NOW(), NOW()
+		FROM (
+			SELECT product_id, unit_price, effective_date,
+				LAG(unit_price) OVER (PARTITION BY product_id ORDER BY effective_date) AS previous
+			FROM (
+				SELECT DISTINCT ON (orders.product_id, (orders.date_of_sale AT TIME ZONE 'UTC')::date)
+					orders.product_id, orders.unit_price, (orders.date_of_sale AT TIME ZONE 'UTC')::date AS effective_date
+				FROM orders
+				WHERE NOT EXISTS (SELECT 1 FROM product_prices WHERE product_prices.product_id = orders.product_id)
+				ORDER BY orders.product_id, (orders.date_of_sale AT TIME ZONE 'UTC')::date, orders.date_of_sale DESC, orders.id DESC
+			) daily
+		) history
+		WHERE previous IS DISTINCT FROM unit_price
+		ON CONFLICT DO NOTHING
+	`).Error; err != nil {
+		return fmt.Errorf("failed to backfill product price history: %v", err)
+	}
+
+	return nil
+}
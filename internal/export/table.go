@@ -0,0 +1,218 @@
+package export
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ColumnType is the kind of value held by an exported column
+type ColumnType int
+
+const (
+	TypeString ColumnType = iota
+	TypeInt
+	TypeFloat
+	TypeBool
+	TypeTime
+)
+
+// Column names and types a column of an exported table
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Table is a flat, typed table whose rows are produced one at a time, so writers can stream
+// them without holding the whole result in memory. Values are string, int64, float64, bool,
+// time.Time or nil.
+type Table struct {
+	Columns []Column
+	each    func(yield func([]interface{}) error) error
+}
+
+// NewTable returns a table whose rows are produced by each. each must call yield once per row
+// with one value per column and stop when yield returns an error.
+func NewTable(columns []Column, each func(yield func([]interface{}) error) error) *Table {
+	return &Table{Columns: columns, each: each}
+}
+
+// EachRow calls fn for every row of the table
+func (t *Table) EachRow(fn func([]interface{}) error) error {
+	return t.each(fn)
+}
+
+// fieldPath locates a flattened column within a row struct
+type fieldPath struct {
+	index []int
+	typ   ColumnType
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// FromSlice builds a table from a slice of structs. Columns are named after the JSON tags;
+// embedded structs are inlined, nested structs are flattened with their name as a prefix and
+// slice or map fields are left out.
+func FromSlice(rows interface{}) (*Table, error) {
+	value := reflect.ValueOf(rows)
+	if value.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("cannot export %T, expected a slice", rows)
+	}
+
+	columns, paths, err := structColumns(value.Type().Elem())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTable(columns, func(yield func([]interface{}) error) error {
+		for i := 0; i < value.Len(); i++ {
+			if err := yield(rowValues(value.Index(i), paths)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}), nil
+}
+
+// FromRows builds a table from structs produced one at a time by each, with the same columns
+// as FromSlice. each must call yield once per row and stop when yield returns an error.
+func FromRows[T any](each func(yield func(T) error) error) (*Table, error) {
+	columns, paths, err := structColumns(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTable(columns, func(yield func([]interface{}) error) error {
+		return each(func(row T) error {
+			return yield(rowValues(reflect.ValueOf(row), paths))
+		})
+	}), nil
+}
+
+// structColumns lists the exported columns of a struct type, or of the struct a pointer type
+// points to
+func structColumns(elem reflect.Type) ([]Column, []fieldPath, error) {
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("cannot export rows of type %s, expected a struct", elem)
+	}
+
+	var (
+		columns []Column
+		paths   []fieldPath
+	)
+	collectFields(elem, "", nil, &columns, &paths)
+	return columns, paths, nil
+}
+
+// rowValues reads the values of every column from a row
+func rowValues(row reflect.Value, paths []fieldPath) []interface{} {
+	if row.Kind() == reflect.Ptr {
+		row = row.Elem()
+	}
+	values := make([]interface{}, len(paths))
+	for i, path := range paths {
+		values[i] = fieldValue(row, path)
+	}
+	return values
+}
+
+// collectFields walks a struct type and records every exportable field
+func collectFields(t reflect.Type, prefix string, index []int, columns *[]Column, paths *[]fieldPath) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		fieldIndex := append(append([]int{}, index...), i)
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			collectFields(fieldType, prefix, fieldIndex, columns, paths)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		name = prefix + name
+
+		columnType, ok := columnTypeOf(fieldType)
+		if !ok {
+			if fieldType.Kind() == reflect.Struct {
+				collectFields(fieldType, name+"_", fieldIndex, columns, paths)
+			}
+			continue
+		}
+
+		*columns = append(*columns, Column{Name: name, Type: columnType})
+		*paths = append(*paths, fieldPath{index: fieldIndex, typ: columnType})
+	}
+}
+
+// columnTypeOf maps a Go type to a column type, reporting false for types that are not scalar
+func columnTypeOf(t reflect.Type) (ColumnType, bool) {
+	if t == timeType {
+		return TypeTime, true
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return TypeString, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return TypeInt, true
+	case reflect.Float32, reflect.Float64:
+		return TypeFloat, true
+	case reflect.Bool:
+		return TypeBool, true
+	}
+	return 0, false
+}
+
+// fieldValue reads a field of row, following pointers and returning nil for nil pointers
+func fieldValue(row reflect.Value, path fieldPath) interface{} {
+	v := row
+	for _, i := range path.index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch path.typ {
+	case TypeTime:
+		return v.Interface().(time.Time)
+	case TypeString:
+		return v.String()
+	case TypeInt:
+		if v.CanInt() {
+			return v.Int()
+		}
+		return int64(v.Uint())
+	case TypeFloat:
+		return v.Float()
+	case TypeBool:
+		return v.Bool()
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/xuri/excelize/v2"
+)
+
+// Supported export formats
+const (
+	FormatJSON    = "json"
+	FormatCSV     = "csv"
+	FormatXLSX    = "xlsx"
+	FormatParquet = "parquet"
+)
+
+// flushEvery is the number of rows written between flushes of a streaming response
+const flushEvery = 500
+
+var contentTypes = map[string]string{
+	FormatJSON:    "application/json",
+	FormatCSV:     "text/csv",
+	FormatXLSX:    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	FormatParquet: "application/vnd.apache.parquet",
+}
+
+// IsValidFormat reports whether format is a supported export format
+func IsValidFormat(format string) bool {
+	_, ok := contentTypes[format]
+	return ok
+}
+
+// ContentType returns the MIME type of a format
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// FormatForContentType returns the format with the given MIME type, or an empty string
+func FormatForContentType(contentType string) string {
+	for format, ct := range contentTypes {
+		if ct == contentType {
+			return format
+		}
+	}
+	return ""
+}
+
+// Write streams the table to w in the given format. CSV rows are flushed to the client as they
+// are written; Excel and Parquet files are only complete once the last row has been written.
+func Write(w io.Writer, format string, table *Table) error {
+	switch format {
+	case FormatCSV:
+		return writeCSV(w, table)
+	case FormatXLSX:
+		return writeXLSX(w, table)
+	case FormatParquet:
+		return writeParquet(w, table)
+	default:
+		return fmt.Errorf("unsupported export format '%s'", format)
+	}
+}
+
+func writeCSV(w io.Writer, table *Table) error {
+	writer := csv.NewWriter(w)
+
+	header := make([]string, len(table.Columns))
+	for i, column := range table.Columns {
+		header[i] = column.Name
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	rows := 0
+	record := make([]string, len(table.Columns))
+	err := table.EachRow(func(values []interface{}) error {
+		for i, value := range values {
+			record[i] = formatValue(value)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+
+		if rows++; rows%flushEvery == 0 {
+			writer.Flush()
+			if flusher, ok := w.(http.Flusher); ok {
+				flusher.Flush()
+			}
+		}
+		return writer.Error()
+	})
+
+	writer.Flush()
+	if err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+func writeXLSX(w io.Writer, table *Table) error {
+	file := excelize.NewFile()
+	defer file.Close()
+
+	sheet := file.GetSheetName(0)
+	stream, err := file.NewStreamWriter(sheet)
+	if err != nil {
+		return err
+	}
+
+	header := make([]interface{}, len(table.Columns))
+	for i, column := range table.Columns {
+		header[i] = column.Name
+	}
+	if err := stream.SetRow("A1", header); err != nil {
+		return err
+	}
+
+	rowNumber := 1
+	err = table.EachRow(func(values []interface{}) error {
+		rowNumber++
+		cell, err := excelize.CoordinatesToCellName(1, rowNumber)
+		if err != nil {
+			return err
+		}
+
+		row := make([]interface{}, len(values))
+		for i, value := range values {
+			// Times are written as text so they read the same as in the CSV and JSON output
+			if t, ok := value.(time.Time); ok {
+				value = formatTime(t)
+			}
+			row[i] = value
+		}
+		return stream.SetRow(cell, row)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := stream.Flush(); err != nil {
+		return err
+	}
+	return file.Write(w)
+}
+
+func writeParquet(w io.Writer, table *Table) error {
+	group := make(parquet.Group, len(table.Columns))
+	for _, column := range table.Columns {
+		group[column.Name] = parquet.Optional(parquetNode(column.Type))
+	}
+	schema := parquet.NewSchema("row", group)
+
+	// Leaf columns are ordered by name in the schema, so map every table column to its index
+	columnIndex := make([]int, len(table.Columns))
+	for i, column := range table.Columns {
+		leaf, ok := schema.Lookup(column.Name)
+		if !ok {
+			return fmt.Errorf("column '%s' missing from parquet schema", column.Name)
+		}
+		columnIndex[i] = leaf.ColumnIndex
+	}
+
+	writer := parquet.NewWriter(w, schema, parquet.MaxRowsPerRowGroup(flushEvery*100))
+
+	err := table.EachRow(func(values []interface{}) error {
+		row := make(parquet.Row, len(values))
+		for i, value := range values {
+			row[columnIndex[i]] = parquetValue(value).Level(0, definitionLevel(value), columnIndex[i])
+		}
+		_, err := writer.WriteRows([]parquet.Row{row})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+func parquetNode(columnType ColumnType) parquet.Node {
+	switch columnType {
+	case TypeInt:
+		return parquet.Int(64)
+	case TypeFloat:
+		return parquet.Leaf(parquet.DoubleType)
+	case TypeBool:
+		return parquet.Leaf(parquet.BooleanType)
+	case TypeTime:
+		return parquet.Timestamp(parquet.Millisecond)
+	default:
+		return parquet.String()
+	}
+}
+
+func parquetValue(value interface{}) parquet.Value {
+	switch v := value.(type) {
+	case string:
+		return parquet.ByteArrayValue([]byte(v))
+	case int64:
+		return parquet.Int64Value(v)
+	case float64:
+		return parquet.DoubleValue(v)
+	case bool:
+		return parquet.BooleanValue(v)
+	case time.Time:
+		return parquet.Int64Value(v.UnixMilli())
+	default:
+		return parquet.NullValue()
+	}
+}
+
+// definitionLevel marks optional values as present (1) or null (0)
+func definitionLevel(value interface{}) int {
+	if value == nil {
+		return 0
+	}
+	return 1
+}
+
+// formatValue renders a value as CSV text
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return formatTime(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// formatTime renders dates without a time of day as YYYY-MM-DD and other times as RFC 3339
+func formatTime(t time.Time) string {
+	if t.Equal(t.Truncate(24 * time.Hour)) {
+		return t.Format("2006-01-02")
+	}
+	return t.Format(time.RFC3339)
+}
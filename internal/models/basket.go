@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// BasketAffinity lists the item pairs most often bought together. A basket is all orders a
+// customer placed on the same day.
+type BasketAffinity struct {
+	Level     string         `json:"level"`
+	StartDate time.Time      `json:"start_date"`
+	EndDate   time.Time      `json:"end_date"`
+	Baskets   int64          `json:"baskets"`
+	Pairs     []AffinityPair `json:"pairs"`
+}
+
+// AffinityPair holds the association metrics of two items:
+// support is the share of all baskets containing both, confidence is the share of baskets
+// containing one item that also contain the other, and lift is how much more often the pair
+// occurs than if the items were bought independently.
+type AffinityPair struct {
+	ItemA        string  `json:"item_a"`
+	ItemAName    string  `json:"item_a_name"`
+	ItemB        string  `json:"item_b"`
+	ItemBName    string  `json:"item_b_name"`
+	PairBaskets  int64   `json:"pair_baskets"`
+	ItemABaskets int64   `json:"item_a_baskets"`
+	ItemBBaskets int64   `json:"item_b_baskets"`
+	Support      float64 `json:"support"`
+	ConfidenceAB float64 `json:"confidence_a_to_b"`
+	ConfidenceBA float64 `json:"confidence_b_to_a"`
+	Lift         float64 `json:"lift"`
+}
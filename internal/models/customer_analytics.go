@@ -0,0 +1,92 @@
+package models
+
+import "time"
+
+// CustomerRevenue is a customer's sales over a period
+type CustomerRevenue struct {
+	CustomerID        string  `json:"customer_id"`
+	CustomerName      string  `json:"customer_name"`
+	Region            string  `json:"region"`
+	Revenue           float64 `json:"revenue"`
+	OrderCount        int64   `json:"order_count"`
+	Quantity          int64   `json:"quantity"`
+	AverageOrderValue float64 `json:"average_order_value"`
+}
+
+// CustomerSummary describes a customer's whole purchase history
+type CustomerSummary struct {
+	CustomerID        string     `json:"customer_id"`
+	Name              string     `json:"name"`
+	Email             string     `json:"email"`
+	Region            string     `json:"region"`
+	FirstPurchase     *time.Time `json:"first_purchase"`
+	LastPurchase      *time.Time `json:"last_purchase"`
+	OrderCount        int64      `json:"order_count"`
+	LifetimeRevenue   float64    `json:"lifetime_revenue"`
+	AverageOrderValue float64    `json:"average_order_value"`
+	FavouriteCategory string     `json:"favourite_category,omitempty"`
+
+	RevenueDefinition RevenueDefinition `json:"revenue_definition"`
+}
+
+// CustomerCounts splits the customers who ordered in a period into new and returning.
+// New customers made their first ever purchase within the period.
+type CustomerCounts struct {
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+	Active    int64     `json:"active"`
+	New       int64     `json:"new"`
+	Returning int64     `json:"returning"`
+}
+
+// CohortRetention groups customers by the month of their first order and tracks how many of
+// them order again in each following month
+type CohortRetention struct {
+	StartDate         time.Time         `json:"start_date"`
+	EndDate           time.Time         `json:"end_date"`
+	RevenueDefinition RevenueDefinition `json:"revenue_definition"`
+	Cohorts           []Cohort          `json:"cohorts"`
+}
+
+type Cohort struct {
+	Month     time.Time      `json:"month"`
+	Customers int64          `json:"customers"`
+	Periods   []CohortPeriod `json:"periods"`
+}
+
+// CohortPeriod is a cohort's activity N months after its first purchase month (month 0)
+type CohortPeriod struct {
+	MonthsSinceFirst int       `json:"months_since_first"`
+	Month            time.Time `json:"month"`
+	ActiveCustomers  int64     `json:"active_customers"`
+	RetentionRate    float64   `json:"retention_rate"`
+	Revenue          float64   `json:"revenue"`
+}
+
+// SegmentCounts lists how many customers fall into each RFM segment as of a reference date
+type SegmentCounts struct {
+	AsOf              time.Time         `json:"as_of"`
+	RevenueDefinition RevenueDefinition `json:"revenue_definition"`
+	Segments          []SegmentCount    `json:"segments"`
+}
+
+type SegmentCount struct {
+	Segment   string `json:"segment"`
+	Customers int64  `json:"customers"`
+}
+
+// SegmentMember is a customer with their recency, frequency and monetary scores (1-5, 5 best)
+type SegmentMember struct {
+	CustomerID     string    `json:"customer_id"`
+	CustomerName   string    `json:"customer_name"`
+	Region         string    `json:"region"`
+	Segment        string    `json:"segment"`
+	LastPurchase   time.Time `json:"last_purchase"`
+	RecencyDays    int       `json:"recency_days"`
+	OrderCount     int64     `json:"order_count"`
+	Quantity       int64     `json:"quantity"`
+	Revenue        float64   `json:"revenue"`
+	RecencyScore   int       `json:"recency_score"`
+	FrequencyScore int       `json:"frequency_score"`
+	MonetaryScore  int       `json:"monetary_score"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// DataVersion identifies the state of the loaded data. Version is the ID of the last refresh job
+// to finish, so it changes whenever a refresh may have changed the data; it is 0 before the first
+// refresh.
+type DataVersion struct {
+	Version   uint      `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
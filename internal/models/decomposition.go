@@ -0,0 +1,36 @@
+package models
+
+// RevenueComponents splits revenue into gross sales, discounts and shipping. Revenue follows the
+// requested revenue definition. DiscountRate is the share of gross sales given away as
+// discounts, and orders with a discount are reported separately from full-price orders.
+type RevenueComponents struct {
+	GrossSales           float64 `json:"gross_sales"`
+	TotalDiscount        float64 `json:"total_discount"`
+	ShippingRevenue      float64 `json:"shipping_revenue"`
+	Revenue              float64 `json:"revenue"`
+	DiscountRate         float64 `gorm:"-" json:"discount_rate"`
+	Quantity             int64   `json:"quantity"`
+	OrderCount           int64   `json:"order_count"`
+	DiscountedOrders     int64   `json:"discounted_orders"`
+	DiscountedGrossSales float64 `json:"discounted_gross_sales"`
+	DiscountedRevenue    float64 `json:"discounted_revenue"`
+	FullPriceOrders      int64   `json:"full_price_orders"`
+	FullPriceGrossSales  float64 `json:"full_price_gross_sales"`
+	FullPriceRevenue     float64 `json:"full_price_revenue"`
+}
+
+// RevenueComponentsRow is the revenue decomposition of a single product, category or region
+type RevenueComponentsRow struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+	RevenueComponents
+}
+
+// RevenueDecomposition holds the decomposition over the whole filtered range and, when GroupBy
+// is set, a page of the decomposition per product, category or region
+type RevenueDecomposition struct {
+	GroupBy           string                      `json:"group_by,omitempty"`
+	RevenueDefinition RevenueDefinition           `json:"revenue_definition"`
+	Total             RevenueComponents           `json:"total"`
+	Breakdown         *Page[RevenueComponentsRow] `json:"breakdown,omitempty"`
+}
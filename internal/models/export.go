@@ -0,0 +1,94 @@
+package models
+
+import "time"
+
+// Exportable is implemented by responses that can be downloaded as CSV, Excel or Parquet.
+// ExportRows returns a slice of structs, one per row of the exported table; nested lists are
+// flattened into one row per item.
+type Exportable interface {
+	ExportRows() interface{}
+}
+
+func (r RevenueResponse) ExportRows() interface{} {
+	return []RevenueResponse{r}
+}
+
+func (p Page[T]) ExportRows() interface{} {
+	return p.Data
+}
+
+func (c TotalRevenueComparison) ExportRows() interface{} {
+	return []TotalRevenueComparison{c}
+}
+
+type timeSeriesRow struct {
+	Key     string    `json:"key"`
+	Label   string    `json:"label"`
+	Period  time.Time `json:"period"`
+	Revenue float64   `json:"revenue"`
+}
+
+func (t RevenueTimeSeries) ExportRows() interface{} {
+	var rows []timeSeriesRow
+	for _, series := range t.Series {
+		for _, point := range series.Points {
+			rows = append(rows, timeSeriesRow{Key: series.Key, Label: series.Label, Period: point.Period, Revenue: point.Revenue})
+		}
+	}
+	return rows
+}
+
+// ExportRows returns the breakdown when one was requested, otherwise the totals
+func (d RevenueDecomposition) ExportRows() interface{} {
+	if d.Breakdown != nil {
+		return d.Breakdown.Data
+	}
+	return []RevenueComponents{d.Total}
+}
+
+type crossTabRow struct {
+	PaymentMethod string `json:"payment_method"`
+	PaymentMethodCrossTabCell
+}
+
+func (t PaymentMethodCrossTab) ExportRows() interface{} {
+	var rows []crossTabRow
+	for _, row := range t.Rows {
+		for _, cell := range row.Cells {
+			rows = append(rows, crossTabRow{PaymentMethod: row.PaymentMethod, PaymentMethodCrossTabCell: cell})
+		}
+	}
+	return rows
+}
+
+func (s CustomerSummary) ExportRows() interface{} {
+	return []CustomerSummary{s}
+}
+
+func (c CustomerCounts) ExportRows() interface{} {
+	return []CustomerCounts{c}
+}
+
+type cohortRow struct {
+	Cohort          time.Time `json:"cohort"`
+	CohortCustomers int64     `json:"cohort_customers"`
+	CohortPeriod
+}
+
+func (r CohortRetention) ExportRows() interface{} {
+	var rows []cohortRow
+	for _, cohort := range r.Cohorts {
+		for _, period := range cohort.Periods {
+			rows = append(rows, cohortRow{Cohort: cohort.Month, CohortCustomers: cohort.Customers, CohortPeriod: period})
+		}
+	}
+	return rows
+}
+
+func (c SegmentCounts) ExportRows() interface{} {
+	return c.Segments
+}
+
+func (b BasketAffinity) ExportRows() interface{} {
+	return b.Pairs
+}
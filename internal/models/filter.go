@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// RevenueFilter narrows every revenue query to a date range and optional dimensions, and
+// carries the definition revenue is calculated with. Empty slices and nil quantities mean no
+// restriction.
+type RevenueFilter struct {
+	StartDate      time.Time `json:"start_date"`
+	EndDate        time.Time `json:"end_date"`
+	Categories     []string  `json:"categories,omitempty"`
+	Regions        []string  `json:"regions,omitempty"`
+	ProductIDs     []string  `json:"product_ids,omitempty"`
+	CustomerIDs    []string  `json:"customer_ids,omitempty"`
+	PaymentMethods []string  `json:"payment_methods,omitempty"`
+	MinQuantity    *int      `json:"min_quantity,omitempty"`
+	MaxQuantity    *int      `json:"max_quantity,omitempty"`
+
+	Revenue RevenueDefinition `json:"revenue"`
+}
+
+// WithDates returns a copy of the filter over a different date range
+func (f RevenueFilter) WithDates(startDate, endDate time.Time) RevenueFilter {
+	f.StartDate = startDate
+	f.EndDate = endDate
+	return f
+}
@@ -6,7 +6,8 @@ import (
 	"gorm.io/gorm"
 )
 
-// Order represents a sales order in the system
+// Order represents a sales order in the system. UnitPrice is the price at the time of sale,
+// so later product price changes do not rewrite historical revenue.
 type Order struct {
 	gorm.Model
 	OrderID       string    `gorm:"column:order_id;uniqueIndex;type:varchar(50)" json:"order_id"`
@@ -14,9 +15,10 @@ type Order struct {
 	ProductID     string    `gorm:"column:product_id;not null;type:varchar(50);index" json:"product_id"`
 	DateOfSale    time.Time `gorm:"column:date_of_sale;not null;index" json:"date_of_sale"`
 	Quantity      int       `gorm:"column:quantity;not null" json:"quantity"`
+	UnitPrice     float64   `gorm:"column:unit_price;type:decimal(10,2)" json:"unit_price"`
 	Discount      float64   `gorm:"column:discount;not null;type:decimal(10,2)" json:"discount"`
 	ShippingCost  float64   `gorm:"column:shipping_cost;not null;type:decimal(10,2)" json:"shipping_cost"`
-	PaymentMethod string    `gorm:"column:payment_method;not null;type:varchar(50)" json:"payment_method"`
+	PaymentMethod string    `gorm:"column:payment_method;not null;type:varchar(50);index" json:"payment_method"`
 }
 
 func (Order) TableName() string {
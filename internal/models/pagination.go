@@ -0,0 +1,28 @@
+package models
+
+// Breakdown sort keys
+const (
+	SortByRevenue  = "revenue"
+	SortByQuantity = "quantity"
+	SortByName     = "name"
+)
+
+// PageRequest selects, orders and pages the rows of a breakdown
+type PageRequest struct {
+	Limit       int
+	Offset      int
+	Sort        string
+	Descending  bool
+	IncludeZero bool
+}
+
+// Page is the response envelope for paginated breakdowns. RevenueDefinition is set when the
+// rows report revenue.
+type Page[T any] struct {
+	Data              []T                `json:"data"`
+	Total             int64              `json:"total"`
+	Limit             int                `json:"limit"`
+	Offset            int                `json:"offset"`
+	NextCursor        string             `json:"next_cursor,omitempty"`
+	RevenueDefinition *RevenueDefinition `json:"revenue_definition,omitempty"`
+}
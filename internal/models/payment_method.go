@@ -0,0 +1,34 @@
+package models
+
+type PaymentMethodRevenue struct {
+	PaymentMethod     string  `json:"payment_method"`
+	Revenue           float64 `json:"revenue"`
+	Quantity          int64   `json:"quantity"`
+	OrderCount        int64   `json:"order_count"`
+	AverageOrderValue float64 `json:"average_order_value"`
+}
+
+// PaymentMethodCrossTab splits payment method usage by region or month. Columns lists the
+// regions or months (YYYY-MM) in order, and every row has one cell per column.
+type PaymentMethodCrossTab struct {
+	By                string                     `json:"by"`
+	RevenueDefinition RevenueDefinition          `json:"revenue_definition"`
+	Columns           []string                   `json:"columns"`
+	Rows              []PaymentMethodCrossTabRow `json:"rows"`
+}
+
+type PaymentMethodCrossTabRow struct {
+	PaymentMethod string                      `json:"payment_method"`
+	Revenue       float64                     `json:"revenue"`
+	OrderCount    int64                       `json:"order_count"`
+	Cells         []PaymentMethodCrossTabCell `json:"cells"`
+}
+
+// PaymentMethodCrossTabCell holds a payment method's orders in one region or month. OrderShare is
+// the fraction of all orders in that column paid with the method.
+type PaymentMethodCrossTabCell struct {
+	Column     string  `json:"column"`
+	Revenue    float64 `json:"revenue"`
+	OrderCount int64   `json:"order_count"`
+	OrderShare float64 `json:"order_share"`
+}
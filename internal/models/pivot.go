@@ -0,0 +1,11 @@
+package models
+
+// PivotRow is one group of a pivot query, keyed by dimension and measure name
+type PivotRow map[string]interface{}
+
+// PivotResult is a page of pivot rows along with the dimensions and measures they hold
+type PivotResult struct {
+	Dimensions []string `json:"dimensions"`
+	Measures   []string `json:"measures"`
+	Page[PivotRow]
+}
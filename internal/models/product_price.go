@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProductPrice records the unit price a product sold at from a given date onwards.
+// A price stays in effect until the next entry for the same product.
+type ProductPrice struct {
+	gorm.Model
+	ProductID     string    `gorm:"column:product_id;not null;type:varchar(50);uniqueIndex:idx_product_prices_product_date" json:"product_id"`
+	UnitPrice     float64   `gorm:"column:unit_price;not null;type:decimal(10,2)" json:"unit_price"`
+	EffectiveDate time.Time `gorm:"column:effective_date;not null;type:date;uniqueIndex:idx_product_prices_product_date" json:"effective_date"`
+}
+
+func (ProductPrice) TableName() string {
+	return "product_prices"
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Refresh job trigger sources
+const (
+	RefreshTriggerManual = "manual"
+	RefreshTriggerCron   = "cron"
+)
+
+// Refresh job load modes. A full load reads the whole file; an incremental load only reads the
+// rows appended since the previous refresh.
+const (
+	RefreshModeFull        = "full"
+	RefreshModeIncremental = "incremental"
+)
+
+// Refresh job statuses
+const (
+	RefreshStatusRunning   = "running"
+	RefreshStatusCompleted = "completed"
+	RefreshStatusFailed    = "failed"
+)
+
+// RefreshJob records a single data refresh run, whether started manually or by cron.
+// UnmappedColumns lists the source headers that were not mapped to any field and were ignored.
+// StartOffset is the byte offset an incremental load started reading from. Reconcile and
+// DeleteMissing record whether changed orders were updated and absent orders soft-deleted;
+// Atomic whether the load went through staging tables.
+type RefreshJob struct {
+	gorm.Model
+	Trigger         string     `gorm:"column:trigger;not null;type:varchar(20)" json:"trigger"`
+	Status          string     `gorm:"column:status;not null;type:varchar(20);index" json:"status"`
+	CSVPath         string     `gorm:"column:csv_path;not null;type:text" json:"csv_path"`
+	Mode            string     `gorm:"column:mode;type:varchar(20)" json:"mode,omitempty"`
+	StartOffset     int64      `gorm:"column:start_offset;not null;default:0" json:"start_offset"`
+	Reconcile       bool       `gorm:"column:reconcile;not null;default:false" json:"reconcile"`
+	DeleteMissing   bool       `gorm:"column:delete_missing;not null;default:false" json:"delete_missing"`
+	Atomic          bool       `gorm:"column:atomic;not null;default:false" json:"atomic"`
+	StartedAt       time.Time  `gorm:"column:started_at;not null" json:"started_at"`
+	FinishedAt      *time.Time `gorm:"column:finished_at" json:"finished_at,omitempty"`
+	RowsRead        int        `gorm:"column:rows_read;not null;default:0" json:"rows_read"`
+	RowsInserted    int        `gorm:"column:rows_inserted;not null;default:0" json:"rows_inserted"`
+	RowsUpdated     int        `gorm:"column:rows_updated;not null;default:0" json:"rows_updated"`
+	RowsSkipped     int        `gorm:"column:rows_skipped;not null;default:0" json:"rows_skipped"`
+	RowsDeleted     int        `gorm:"column:rows_deleted;not null;default:0" json:"rows_deleted"`
+	RowsRejected    int        `gorm:"column:rows_rejected;not null;default:0" json:"rows_rejected"`
+	UnmappedColumns string     `gorm:"column:unmapped_columns;type:text" json:"unmapped_columns,omitempty"`
+	Error           string     `gorm:"column:error;type:text" json:"error,omitempty"`
+}
+
+func (RefreshJob) TableName() string {
+	return "refresh_jobs"
+}
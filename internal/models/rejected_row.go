@@ -0,0 +1,16 @@
+package models
+
+import "gorm.io/gorm"
+
+// RejectedRow is a CSV row that failed validation during a refresh job
+type RejectedRow struct {
+	gorm.Model
+	JobID      uint   `gorm:"column:job_id;not null;index;index:idx_rejected_rows_job_line,priority:1" json:"job_id"`
+	LineNumber int    `gorm:"column:line_number;not null;index:idx_rejected_rows_job_line,priority:2" json:"line_number"`
+	RawRow     string `gorm:"column:raw_row;not null;type:text" json:"raw_row"`
+	Reason     string `gorm:"column:reason;not null;type:text" json:"reason"`
+}
+
+func (RejectedRow) TableName() string {
+	return "rejected_rows"
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Report job statuses
+const (
+	ReportStatusQueued    = "queued"
+	ReportStatusRunning   = "running"
+	ReportStatusCompleted = "completed"
+	ReportStatusFailed    = "failed"
+	ReportStatusExpired   = "expired"
+)
+
+// ReportSpec describes a report: a pivot of the orders in a date range, written as a csv, xlsx
+// or parquet file. Dates use the YYYY-MM-DD format and the filters match the revenue query
+// parameters. RevenueDefinition falls back to the deployment default when omitted.
+type ReportSpec struct {
+	StartDate         string             `json:"start_date"`
+	EndDate           string             `json:"end_date"`
+	Categories        []string           `json:"categories,omitempty"`
+	Regions           []string           `json:"regions,omitempty"`
+	ProductIDs        []string           `json:"product_ids,omitempty"`
+	CustomerIDs       []string           `json:"customer_ids,omitempty"`
+	PaymentMethods    []string           `json:"payment_methods,omitempty"`
+	MinQuantity       *int               `json:"min_quantity,omitempty"`
+	MaxQuantity       *int               `json:"max_quantity,omitempty"`
+	Dimensions        []string           `json:"dimensions"`
+	Measures          []string           `json:"measures"`
+	Sort              string             `json:"sort,omitempty"`
+	Order             string             `json:"order,omitempty"`
+	Format            string             `json:"format,omitempty"`
+	RevenueDefinition *RevenueDefinition `json:"revenue_definition,omitempty"`
+}
+
+// ReportJob tracks the generation of a report file by the background workers. The file is
+// removed and the job marked expired once ExpiresAt has passed.
+type ReportJob struct {
+	gorm.Model
+	Status      string     `gorm:"column:status;not null;type:varchar(20);index" json:"status"`
+	Spec        ReportSpec `gorm:"column:spec;not null;type:text;serializer:json" json:"spec"`
+	FilePath    string     `gorm:"column:file_path;type:text" json:"-"`
+	FileSize    int64      `gorm:"column:file_size;not null;default:0" json:"file_size"`
+	RowCount    int64      `gorm:"column:row_count;not null;default:0" json:"row_count"`
+	StartedAt   *time.Time `gorm:"column:started_at" json:"started_at,omitempty"`
+	FinishedAt  *time.Time `gorm:"column:finished_at" json:"finished_at,omitempty"`
+	ExpiresAt   *time.Time `gorm:"column:expires_at;index" json:"expires_at,omitempty"`
+	Error       string     `gorm:"column:error;type:text" json:"error,omitempty"`
+	DownloadURL string     `gorm:"-" json:"download_url,omitempty"`
+}
+
+func (ReportJob) TableName() string {
+	return "report_jobs"
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Report schedule delivery targets
+const (
+	ReportDeliveryDirectory = "directory"
+	ReportDeliveryWebhook   = "webhook"
+)
+
+// Report schedule run triggers
+const (
+	ReportTriggerCron   = "cron"
+	ReportTriggerManual = "manual"
+)
+
+// Report schedule run statuses
+const (
+	ReportRunRunning   = "running"
+	ReportRunCompleted = "completed"
+	ReportRunFailed    = "failed"
+)
+
+// ReportSchedule generates a report on a cron schedule and delivers it to the drop directory or
+// a webhook. When Period is set (day, week, month, quarter or year), each run covers the previous
+// full period and the dates in Report are ignored.
+type ReportSchedule struct {
+	gorm.Model
+	Name       string     `gorm:"column:name;not null;type:text" json:"name"`
+	CronSpec   string     `gorm:"column:cron_spec;not null;type:varchar(100)" json:"cron_spec"`
+	Period     string     `gorm:"column:period;type:varchar(20)" json:"period,omitempty"`
+	Report     ReportSpec `gorm:"column:report;not null;type:text;serializer:json" json:"report"`
+	Delivery   string     `gorm:"column:delivery;not null;type:varchar(20)" json:"delivery"`
+	WebhookURL string     `gorm:"column:webhook_url;type:text" json:"webhook_url,omitempty"`
+}
+
+func (ReportSchedule) TableName() string {
+	return "report_schedules"
+}
+
+// ReportScheduleRun records a single run of a report schedule. Destination is the file written
+// to the drop directory or the webhook URL the report was posted to.
+type ReportScheduleRun struct {
+	gorm.Model
+	ScheduleID     uint       `gorm:"column:schedule_id;not null;index" json:"schedule_id"`
+	Trigger        string     `gorm:"column:trigger;not null;type:varchar(20)" json:"trigger"`
+	Status         string     `gorm:"column:status;not null;type:varchar(20)" json:"status"`
+	StartDate      string     `gorm:"column:start_date;type:varchar(10)" json:"start_date"`
+	EndDate        string     `gorm:"column:end_date;type:varchar(10)" json:"end_date"`
+	StartedAt      time.Time  `gorm:"column:started_at;not null" json:"started_at"`
+	FinishedAt     *time.Time `gorm:"column:finished_at" json:"finished_at,omitempty"`
+	RowCount       int64      `gorm:"column:row_count;not null;default:0" json:"row_count"`
+	FileSize       int64      `gorm:"column:file_size;not null;default:0" json:"file_size"`
+	Destination    string     `gorm:"column:destination;type:text" json:"destination,omitempty"`
+	ResponseStatus int        `gorm:"column:response_status" json:"response_status,omitempty"`
+	Error          string     `gorm:"column:error;type:text" json:"error,omitempty"`
+}
+
+func (ReportScheduleRun) TableName() string {
+	return "report_schedule_runs"
+}
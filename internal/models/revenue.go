@@ -1,22 +1,102 @@
 package models
 
+import "time"
+
 // Revenue response structures for API responses
 type RevenueResponse struct {
-	TotalRevenue float64 `json:"total_revenue"`
+	TotalRevenue      float64           `json:"total_revenue"`
+	RevenueDefinition RevenueDefinition `json:"revenue_definition"`
 }
 
 type ProductRevenue struct {
 	ProductID   string  `json:"product_id"`
 	ProductName string  `json:"product_name"`
 	Revenue     float64 `json:"revenue"`
+	Quantity    int64   `json:"quantity"`
 }
 
 type CategoryRevenue struct {
 	Category string  `json:"category"`
 	Revenue  float64 `json:"revenue"`
+	Quantity int64   `json:"quantity"`
 }
 
 type RegionRevenue struct {
-	Region  string  `json:"region"`
-	Revenue float64 `json:"revenue"`
+	Region   string  `json:"region"`
+	Revenue  float64 `json:"revenue"`
+	Quantity int64   `json:"quantity"`
+}
+
+// RevenueTimeSeries holds revenue bucketed by period, optionally split into one series per group
+type RevenueTimeSeries struct {
+	Granularity       string            `json:"granularity"`
+	GroupBy           string            `json:"group_by,omitempty"`
+	Limit             int               `json:"limit,omitempty"`
+	RevenueDefinition RevenueDefinition `json:"revenue_definition"`
+	Series            []RevenueSeries   `json:"series"`
+}
+
+// RevenueSeries is the revenue of one group over time. Other marks the series summing the
+// groups beyond the limit.
+type RevenueSeries struct {
+	Key    string         `json:"key,omitempty"`
+	Label  string         `json:"label,omitempty"`
+	Other  bool           `json:"other,omitempty"`
+	Total  float64        `json:"total"`
+	Points []RevenuePoint `json:"points"`
+}
+
+type RevenuePoint struct {
+	Period  time.Time `json:"period"`
+	Revenue float64   `json:"revenue"`
+}
+
+// Period is an inclusive date range
+type Period struct {
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+}
+
+// RevenueDelta compares revenue in the requested period against the comparison period.
+// PercentChange is null when the comparison revenue is zero.
+type RevenueDelta struct {
+	Revenue           float64  `json:"revenue"`
+	ComparisonRevenue float64  `json:"comparison_revenue"`
+	Delta             float64  `json:"delta"`
+	PercentChange     *float64 `json:"percent_change"`
+}
+
+// RevenueComparison wraps a page of comparison rows with the periods they were computed over.
+// IncludesPreviousOnly reports whether rows with revenue only in the comparison period are
+// listed, with zero revenue, or left out because zero rows were excluded.
+type RevenueComparison[T any] struct {
+	Compare              string `json:"compare"`
+	CurrentPeriod        Period `json:"current_period"`
+	ComparisonPeriod     Period `json:"comparison_period"`
+	IncludesPreviousOnly bool   `json:"includes_previous_only"`
+	Page[T]
+}
+
+type TotalRevenueComparison struct {
+	Compare           string            `json:"compare"`
+	CurrentPeriod     Period            `json:"current_period"`
+	ComparisonPeriod  Period            `json:"comparison_period"`
+	RevenueDefinition RevenueDefinition `json:"revenue_definition"`
+	RevenueDelta
+}
+
+type ProductRevenueComparison struct {
+	ProductID   string `json:"product_id"`
+	ProductName string `json:"product_name"`
+	RevenueDelta
+}
+
+type CategoryRevenueComparison struct {
+	Category string `json:"category"`
+	RevenueDelta
+}
+
+type RegionRevenueComparison struct {
+	Region string `json:"region"`
+	RevenueDelta
 }
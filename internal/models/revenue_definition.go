@@ -0,0 +1,22 @@
+package models
+
+// Revenue bases
+const (
+	RevenueBasisGross = "gross"
+	RevenueBasisNet   = "net"
+)
+
+// Ways the discount recorded on an order can be interpreted
+const (
+	DiscountTypeAbsolute   = "absolute"
+	DiscountTypePercentage = "percentage"
+)
+
+// RevenueDefinition selects how the revenue of an order is calculated. Gross revenue is unit
+// price × quantity; net revenue also subtracts the discount, read either as an amount or as a
+// percentage of gross. Shipping cost is optionally added on top of either.
+type RevenueDefinition struct {
+	Basis           string `json:"basis"`
+	DiscountType    string `json:"discount_type"`
+	IncludeShipping bool   `json:"include_shipping"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// DailyRevenue holds the additive components of revenue for one day, so the rollups can answer
+// queries under any revenue definition. PercentageDiscount is the discount amount when discounts
+// are read as percentages of gross sales.
+type DailyRevenue struct {
+	GrossSales         float64 `gorm:"column:gross_sales;not null;type:numeric"`
+	Discount           float64 `gorm:"column:discount;not null;type:numeric"`
+	PercentageDiscount float64 `gorm:"column:percentage_discount;not null;type:numeric"`
+	ShippingCost       float64 `gorm:"column:shipping_cost;not null;type:numeric"`
+	Quantity           int64   `gorm:"column:quantity;not null"`
+	OrderCount         int64   `gorm:"column:order_count;not null"`
+}
+
+// DailyProductRevenue rolls up orders by day of sale and product
+type DailyProductRevenue struct {
+	Date      time.Time `gorm:"column:date;primaryKey;type:date"`
+	ProductID string    `gorm:"column:product_id;primaryKey;type:varchar(50);index"`
+	DailyRevenue
+}
+
+func (DailyProductRevenue) TableName() string {
+	return "daily_product_revenue"
+}
+
+// DailyRegionRevenue rolls up orders by day of sale and customer region
+type DailyRegionRevenue struct {
+	Date   time.Time `gorm:"column:date;primaryKey;type:date"`
+	Region string    `gorm:"column:region;primaryKey;type:varchar(100);index"`
+	DailyRevenue
+}
+
+func (DailyRegionRevenue) TableName() string {
+	return "daily_region_revenue"
+}
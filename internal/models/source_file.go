@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// SourceFile remembers how far a CSV file has been loaded, so later refreshes only read the rows
+// appended since. ByteOffset is the byte offset after the last loaded row and Line the number of
+// lines up to it. Fingerprint hashes the start of the file and the bytes just before ByteOffset to
+// detect a rewritten file.
+type SourceFile struct {
+	Path        string    `gorm:"column:path;primaryKey;type:text" json:"path"`
+	Header      string    `gorm:"column:header;not null;type:text" json:"header"`
+	ByteOffset  int64     `gorm:"column:byte_offset;not null" json:"byte_offset"`
+	Line        int       `gorm:"column:line;not null" json:"line"`
+	Fingerprint string    `gorm:"column:fingerprint;not null;type:varchar(64)" json:"fingerprint"`
+	UpdatedAt   time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (SourceFile) TableName() string {
+	return "source_files"
+}
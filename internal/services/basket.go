@@ -0,0 +1,193 @@
+package services
+
+import (
+	"fmt"
+
+	"sales-analytics/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Basket affinity levels
+const (
+	AffinityLevelProduct  = "product"
+	AffinityLevelCategory = "category"
+)
+
+// Basket affinity sort keys
+const (
+	AffinitySortSupport    = "support"
+	AffinitySortConfidence = "confidence"
+	AffinitySortLift       = "lift"
+)
+
+// affinityItems holds the SQL columns identifying and naming an item at each level
+var affinityItems = map[string]seriesGroup{
+	AffinityLevelProduct:  {keyColumn: "products.product_id", labelColumn: "products.name"},
+	AffinityLevelCategory: {keyColumn: "products.category", labelColumn: "products.category"},
+}
+
+var affinitySortColumns = map[string]string{
+	AffinitySortSupport:    "support",
+	AffinitySortConfidence: "GREATEST(confidence_a_b, confidence_b_a)",
+	AffinitySortLift:       "lift",
+}
+
+// IsValidAffinityLevel reports whether level is a supported basket affinity level
+func IsValidAffinityLevel(level string) bool {
+	_, ok := affinityItems[level]
+	return ok
+}
+
+// IsValidAffinitySort reports whether sort is a supported basket affinity sort key
+func IsValidAffinitySort(sort string) bool {
+	_, ok := affinitySortColumns[sort]
+	return ok
+}
+
+// AffinityOptions controls which item pairs are returned
+type AffinityOptions struct {
+	Level      string
+	Sort       string
+	Limit      int
+	MinSupport float64
+}
+
+type BasketService struct {
+	db *gorm.DB
+}
+
+func NewBasketService(db *gorm.DB) *BasketService {
+	return &BasketService{db: db}
+}
+
+// GetBasketAffinity treats every (customer, date of sale) as a basket and returns the top item
+// pairs bought together within the filtered orders
+func (s *BasketService) GetBasketAffinity(filter models.RevenueFilter, opts AffinityOptions) (*models.BasketAffinity, error) {
+	item, ok := affinityItems[opts.Level]
+	if !ok {
+		return nil, fmt.Errorf("unsupported affinity level '%s'", opts.Level)
+	}
+	sortColumn, ok := affinitySortColumns[opts.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unsupported affinity sort '%s'", opts.Sort)
+	}
+
+	// basketItems lists each distinct item once per basket
+	basketItems := func() *gorm.DB {
+		return filteredOrders(s.db, filter).
+			Distinct("orders.customer_id", "orders.date_of_sale", item.keyColumn+" as item")
+	}
+
+	result := &models.BasketAffinity{
+		Level:     opts.Level,
+		StartDate: filter.StartDate,
+		EndDate:   filter.EndDate,
+		Pairs:     []models.AffinityPair{},
+	}
+
+	baskets := filteredOrders(s.db, filter).Distinct("orders.customer_id", "orders.date_of_sale")
+	if err := s.db.Table("(?) baskets", baskets).Count(&result.Baskets).Error; err != nil {
+		return nil, fmt.Errorf("error counting baskets: %v", err)
+	}
+	if result.Baskets == 0 {
+		return result, nil
+	}
+
+	itemBaskets := s.db.Table("(?) basket_items", basketItems()).
+		Select("basket_items.item, COUNT(*) as baskets").
+		Group("basket_items.item")
+
+	pairs := s.db.Table("(?) a", basketItems()).
+		Select("a.item as item_a, b.item as item_b, COUNT(*) as pair_baskets").
+		Joins("JOIN (?) b ON b.customer_id = a.customer_id AND b.date_of_sale = a.date_of_sale AND a.item < b.item", basketItems()).
+		Group("a.item, b.item")
+
+	total := float64(result.Baskets)
+	metrics := s.db.Table("(?) pairs", pairs).
+		Select("pairs.item_a, pairs.item_b, pairs.pair_baskets, "+
+			"count_a.baskets as item_a_baskets, count_b.baskets as item_b_baskets, "+
+			"pairs.pair_baskets / CAST(? AS double precision) as support, "+
+			"CAST(pairs.pair_baskets AS double precision) / count_a.baskets as confidence_a_b, "+
+			"CAST(pairs.pair_baskets AS double precision) / count_b.baskets as confidence_b_a, "+
+			"pairs.pair_baskets * CAST(? AS double precision) / (count_a.baskets * count_b.baskets) as lift", total, total).
+		Joins("JOIN (?) count_a ON count_a.item = pairs.item_a", itemBaskets).
+		Joins("JOIN (?) count_b ON count_b.item = pairs.item_b", itemBaskets)
+
+	var rows []struct {
+		ItemA        string
+		ItemB        string
+		PairBaskets  int64
+		ItemABaskets int64
+		ItemBBaskets int64
+		Support      float64
+		ConfidenceAB float64 `gorm:"column:confidence_a_b"`
+		ConfidenceBA float64 `gorm:"column:confidence_b_a"`
+		Lift         float64
+	}
+
+	query := s.db.Table("(?) metrics", metrics).
+		Where("metrics.support >= ?", opts.MinSupport).
+		Order(sortColumn + " DESC, metrics.pair_baskets DESC, metrics.item_a, metrics.item_b")
+	if opts.Limit > 0 {
+		query = query.Limit(opts.Limit)
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("error querying basket affinity: %v", err)
+	}
+
+	keys := make([]string, 0, len(rows)*2)
+	for _, row := range rows {
+		keys = append(keys, row.ItemA, row.ItemB)
+	}
+	names, err := s.itemNames(item, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		result.Pairs = append(result.Pairs, models.AffinityPair{
+			ItemA:        row.ItemA,
+			ItemAName:    names[row.ItemA],
+			ItemB:        row.ItemB,
+			ItemBName:    names[row.ItemB],
+			PairBaskets:  row.PairBaskets,
+			ItemABaskets: row.ItemABaskets,
+			ItemBBaskets: row.ItemBBaskets,
+			Support:      row.Support,
+			ConfidenceAB: row.ConfidenceAB,
+			ConfidenceBA: row.ConfidenceBA,
+			Lift:         row.Lift,
+		})
+	}
+
+	return result, nil
+}
+
+// itemNames looks up the display names of items, falling back to the item key itself
+func (s *BasketService) itemNames(item seriesGroup, keys []string) (map[string]string, error) {
+	names := make(map[string]string, len(keys))
+	for _, key := range keys {
+		names[key] = key
+	}
+	if len(keys) == 0 || item.keyColumn == item.labelColumn {
+		return names, nil
+	}
+
+	var labels []struct {
+		Key   string
+		Label string
+	}
+	err := s.db.Model(&models.Product{}).
+		Select(item.keyColumn+" as key, "+item.labelColumn+" as label").
+		Where(item.keyColumn+" IN ?", keys).
+		Scan(&labels).Error
+	if err != nil {
+		return nil, fmt.Errorf("error querying item names: %v", err)
+	}
+	for _, l := range labels {
+		names[l.Key] = l.Label
+	}
+
+	return names, nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"sales-analytics/internal/models"
+)
+
+// monthExpr truncates an order's date of sale to the first day of its month
+const monthExpr = "date_trunc('month', orders.date_of_sale AT TIME ZONE 'UTC')"
+
+// MaxCohortMonths is the largest number of months a cohort retention matrix may span, as the
+// matrix grows with the square of the months
+const MaxCohortMonths = 120
+
+// GetCohortRetention groups customers by the month of their first order and returns, for each
+// cohort starting within the filtered range, the share of customers ordering again and the
+// revenue in every month up to the end of the range. Dimension filters apply to both the first
+// order and later activity.
+func (s *CustomerAnalyticsService) GetCohortRetention(filter models.RevenueFilter) (*models.CohortRetention, error) {
+	// First orders can predate the range, so look at all history up to the end date
+	history := filter.WithDates(time.Time{}, filter.EndDate)
+
+	firstMonths := filteredOrders(s.db, history).
+		Select("orders.customer_id, MIN(" + monthExpr + ") as cohort").
+		Group("orders.customer_id")
+
+	activity := filteredOrders(s.db, history).
+		Select("orders.customer_id, " + monthExpr + " as month, SUM(" + revenueExpr(filter.Revenue) + ") as revenue").
+		Group("1, 2")
+
+	var rows []struct {
+		Cohort    time.Time
+		Month     time.Time
+		Customers int64
+		Revenue   float64
+	}
+
+	firstCohort := truncatePeriod(filter.StartDate, GranularityMonth)
+	err := s.db.Table("(?) activity", activity).
+		Select("first_months.cohort, activity.month, COUNT(*) as customers, SUM(activity.revenue) as revenue").
+		Joins("JOIN (?) first_months ON first_months.customer_id = activity.customer_id", firstMonths).
+		// The cohort is a timestamp without time zone, so compare it to a date rather than a
+		// time.Time, which would be converted using the session time zone
+		Where("first_months.cohort >= ?", firstCohort.Format("2006-01-02")).
+		Group("1, 2").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("error querying cohort activity: %v", err)
+	}
+
+	type cell struct {
+		customers int64
+		revenue   float64
+	}
+	cells := make(map[time.Time]map[time.Time]cell)
+	for _, row := range rows {
+		cohort := row.Cohort.UTC()
+		if cells[cohort] == nil {
+			cells[cohort] = make(map[time.Time]cell)
+		}
+		cells[cohort][row.Month.UTC()] = cell{customers: row.Customers, revenue: row.Revenue}
+	}
+
+	months := periodBuckets(filter.StartDate, filter.EndDate, GranularityMonth)
+	result := &models.CohortRetention{
+		StartDate:         filter.StartDate,
+		EndDate:           filter.EndDate,
+		RevenueDefinition: filter.Revenue,
+		Cohorts:           make([]models.Cohort, 0, len(months)),
+	}
+
+	for i, cohortMonth := range months {
+		cohort := models.Cohort{
+			Month:     cohortMonth,
+			Customers: cells[cohortMonth][cohortMonth].customers,
+			Periods:   make([]models.CohortPeriod, 0, len(months)-i),
+		}
+		for n, month := range months[i:] {
+			c := cells[cohortMonth][month]
+			period := models.CohortPeriod{
+				MonthsSinceFirst: n,
+				Month:            month,
+				ActiveCustomers:  c.customers,
+				Revenue:          c.revenue,
+			}
+			if cohort.Customers > 0 {
+				period.RetentionRate = float64(c.customers) / float64(cohort.Customers)
+			}
+			cohort.Periods = append(cohort.Periods, period)
+		}
+		result.Cohorts = append(result.Cohorts, cohort)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Order/Product/Customer fields that can be read from the source CSV
+const (
+	FieldOrderID         = "order_id"
+	FieldProductID       = "product_id"
+	FieldCustomerID      = "customer_id"
+	FieldProductName     = "product_name"
+	FieldCategory        = "category"
+	FieldRegion          = "region"
+	FieldDateOfSale      = "date_of_sale"
+	FieldQuantity        = "quantity"
+	FieldUnitPrice       = "unit_price"
+	FieldDiscount        = "discount"
+	FieldShippingCost    = "shipping_cost"
+	FieldPaymentMethod   = "payment_method"
+	FieldCustomerName    = "customer_name"
+	FieldCustomerEmail   = "customer_email"
+	FieldCustomerAddress = "customer_address"
+)
+
+// defaultColumnHeaders maps every field to the header used by the standard export
+var defaultColumnHeaders = map[string]string{
+	FieldOrderID:         "Order ID",
+	FieldProductID:       "Product ID",
+	FieldCustomerID:      "Customer ID",
+	FieldProductName:     "Product Name",
+	FieldCategory:        "Category",
+	FieldRegion:          "Region",
+	FieldDateOfSale:      "Date of Sale",
+	FieldQuantity:        "Quantity Sold",
+	FieldUnitPrice:       "Unit Price",
+	FieldDiscount:        "Discount",
+	FieldShippingCost:    "Shipping Cost",
+	FieldPaymentMethod:   "Payment Method",
+	FieldCustomerName:    "Customer Name",
+	FieldCustomerEmail:   "Customer Email",
+	FieldCustomerAddress: "Customer Address",
+}
+
+// optionalFields may be absent from the source file; they load as empty strings or zero
+var optionalFields = map[string]bool{
+	FieldDiscount:        true,
+	FieldShippingCost:    true,
+	FieldPaymentMethod:   true,
+	FieldCustomerName:    true,
+	FieldCustomerAddress: true,
+}
+
+// ColumnMapping maps Order/Product/Customer fields to source CSV header names
+type ColumnMapping map[string]string
+
+// NewColumnMapping returns the default mapping with the given field-to-header overrides applied
+func NewColumnMapping(overrides map[string]string) (ColumnMapping, error) {
+	mapping := make(ColumnMapping, len(defaultColumnHeaders))
+	for field, header := range defaultColumnHeaders {
+		mapping[field] = header
+	}
+
+	for field, header := range overrides {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if _, ok := defaultColumnHeaders[field]; !ok {
+			return nil, fmt.Errorf("unknown CSV column mapping field '%s'", field)
+		}
+		if strings.TrimSpace(header) == "" {
+			return nil, fmt.Errorf("empty CSV header for column mapping field '%s'", field)
+		}
+		mapping[field] = header
+	}
+
+	return mapping, nil
+}
+
+// columnIndex holds the position of each mapped field within a CSV record
+type columnIndex struct {
+	positions map[string]int
+	width     int
+}
+
+// value returns the trimmed value of a field, or an empty string if the field is not present
+func (c columnIndex) value(record []string, field string) string {
+	idx, ok := c.positions[field]
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(record[idx])
+}
+
+// raw returns the untrimmed value of a field for use in error messages
+func (c columnIndex) raw(record []string, field string) string {
+	idx, ok := c.positions[field]
+	if !ok {
+		return ""
+	}
+	return record[idx]
+}
+
+// resolve matches the header row against the mapping. It fails if a required field has no
+// column and returns the headers that are not mapped to any field.
+func (m ColumnMapping) resolve(header []string) (columnIndex, []string, error) {
+	byHeader := make(map[string]int, len(header))
+	duplicates := make(map[string]bool)
+	for i, name := range header {
+		key := normalizeHeader(name)
+		if _, dup := byHeader[key]; dup {
+			duplicates[key] = true
+			continue
+		}
+		byHeader[key] = i
+	}
+
+	index := columnIndex{positions: make(map[string]int, len(m)), width: len(header)}
+	mapped := make(map[int]bool, len(m))
+	var missing []string
+
+	for field, name := range m {
+		key := normalizeHeader(name)
+		if duplicates[key] {
+			return columnIndex{}, nil, fmt.Errorf("CSV column '%s' (%s) appears more than once", name, field)
+		}
+		pos, ok := byHeader[key]
+		if !ok {
+			if !optionalFields[field] {
+				missing = append(missing, fmt.Sprintf("%s (%s)", name, field))
+			}
+			continue
+		}
+		index.positions[field] = pos
+		mapped[pos] = true
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return columnIndex{}, nil, fmt.Errorf("CSV is missing required columns: %s", strings.Join(missing, ", "))
+	}
+
+	var unmapped []string
+	for i, name := range header {
+		if !mapped[i] {
+			unmapped = append(unmapped, strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		}
+	}
+
+	return index, unmapped, nil
+}
+
+// normalizeHeader makes header matching insensitive to case, surrounding whitespace and the
+// UTF-8 byte order mark some spreadsheet exports prepend to the first column
+func normalizeHeader(name string) string {
+	return strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+}
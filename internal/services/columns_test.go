@@ -0,0 +1,140 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewColumnMapping(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[string]string
+		want      map[string]string
+		wantErr   string
+	}{
+		{name: "defaults", want: map[string]string{FieldQuantity: "Quantity Sold", FieldOrderID: "Order ID"}},
+		{
+			name:      "override",
+			overrides: map[string]string{FieldQuantity: "Qty"},
+			want:      map[string]string{FieldQuantity: "Qty", FieldOrderID: "Order ID"},
+		},
+		{
+			name:      "field names are normalized",
+			overrides: map[string]string{" Order_ID ": "Order Number"},
+			want:      map[string]string{FieldOrderID: "Order Number"},
+		},
+		{name: "unknown field", overrides: map[string]string{"sku": "SKU"}, wantErr: "unknown CSV column mapping field 'sku'"},
+		{name: "empty header", overrides: map[string]string{FieldRegion: " "}, wantErr: "empty CSV header for column mapping field 'region'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapping, err := NewColumnMapping(tt.overrides)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("NewColumnMapping error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewColumnMapping: %v", err)
+			}
+			if len(mapping) != len(defaultColumnHeaders) {
+				t.Errorf("mapping has %d fields, want %d", len(mapping), len(defaultColumnHeaders))
+			}
+			for field, header := range tt.want {
+				if mapping[field] != header {
+					t.Errorf("mapping[%s] = %q, want %q", field, mapping[field], header)
+				}
+			}
+		})
+	}
+}
+
+func TestColumnMappingResolve(t *testing.T) {
+	required := []string{
+		"Order ID", "Product ID", "Customer ID", "Product Name", "Category", "Region", "Date of Sale",
+		"Quantity Sold", "Unit Price", "Customer Email",
+	}
+	with := func(extra ...string) []string {
+		return append(append([]string{}, required...), extra...)
+	}
+
+	tests := []struct {
+		name      string
+		header    []string
+		overrides map[string]string
+		positions map[string]int
+		unmapped  []string
+		wantErr   string
+	}{
+		{
+			name:      "required columns only",
+			header:    required,
+			positions: map[string]int{FieldOrderID: 0, FieldCustomerEmail: 9},
+		},
+		{
+			name:      "case, whitespace and byte order mark ignored",
+			header:    append([]string{"\ufeffORDER id "}, required[1:]...),
+			positions: map[string]int{FieldOrderID: 0, FieldProductID: 1},
+		},
+		{
+			name:      "optional and unmapped columns",
+			header:    with("Discount", "Notes"),
+			positions: map[string]int{FieldDiscount: 10},
+			unmapped:  []string{"Notes"},
+		},
+		{
+			name:      "mapped header",
+			header:    append([]string{"Order Number"}, required[1:]...),
+			overrides: map[string]string{FieldOrderID: "Order Number"},
+			positions: map[string]int{FieldOrderID: 0},
+		},
+		{
+			name:    "missing required columns",
+			header:  required[2:],
+			wantErr: "CSV is missing required columns: Order ID (order_id), Product ID (product_id)",
+		},
+		{
+			name:    "duplicate mapped column",
+			header:  with("region"),
+			wantErr: "CSV column 'Region' (region) appears more than once",
+		},
+		{
+			name:     "duplicate unmapped column",
+			header:   with("Notes", "Notes"),
+			unmapped: []string{"Notes", "Notes"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapping, err := NewColumnMapping(tt.overrides)
+			if err != nil {
+				t.Fatalf("NewColumnMapping: %v", err)
+			}
+
+			cols, unmapped, err := mapping.resolve(tt.header)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("resolve error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolve: %v", err)
+			}
+			if cols.width != len(tt.header) {
+				t.Errorf("width = %d, want %d", cols.width, len(tt.header))
+			}
+			for field, pos := range tt.positions {
+				if got, ok := cols.positions[field]; !ok || got != pos {
+					t.Errorf("position of %s = %d (present %v), want %d", field, got, ok, pos)
+				}
+			}
+			if !reflect.DeepEqual(unmapped, tt.unmapped) {
+				t.Errorf("unmapped = %q, want %q", unmapped, tt.unmapped)
+			}
+		})
+	}
+}
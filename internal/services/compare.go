@@ -0,0 +1,167 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"sales-analytics/internal/models"
+)
+
+// Supported period-over-period comparisons
+const (
+	ComparePreviousPeriod = "previous_period"
+	ComparePreviousYear   = "previous_year"
+)
+
+// IsValidComparison reports whether mode is a supported comparison
+func IsValidComparison(mode string) bool {
+	return mode == ComparePreviousPeriod || mode == ComparePreviousYear
+}
+
+// ComparisonRange returns the range that startDate..endDate is compared against. The previous
+// period has the same number of days and ends the day before startDate.
+func ComparisonRange(startDate, endDate time.Time, mode string) (time.Time, time.Time, error) {
+	switch mode {
+	case ComparePreviousPeriod:
+		days := int(endDate.Sub(startDate).Hours()/24) + 1
+		prevEnd := startDate.AddDate(0, 0, -1)
+		return prevEnd.AddDate(0, 0, -(days - 1)), prevEnd, nil
+	case ComparePreviousYear:
+		return startDate.AddDate(-1, 0, 0), endDate.AddDate(-1, 0, 0), nil
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("unsupported comparison '%s'", mode)
+	}
+}
+
+// newRevenueDelta computes the absolute and percent change between two revenue values
+func newRevenueDelta(current, previous float64) models.RevenueDelta {
+	delta := models.RevenueDelta{
+		Revenue:           current,
+		ComparisonRevenue: previous,
+		Delta:             current - previous,
+	}
+	if previous != 0 {
+		pct := (current - previous) / previous * 100
+		delta.PercentChange = &pct
+	}
+	return delta
+}
+
+// CompareTotalRevenue returns total revenue for the filter alongside the comparison range
+func (s *RevenueService) CompareTotalRevenue(filter models.RevenueFilter, mode string) (*models.TotalRevenueComparison, error) {
+	prevStart, prevEnd, err := ComparisonRange(filter.StartDate, filter.EndDate, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.GetTotalRevenue(filter)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.GetTotalRevenue(filter.WithDates(prevStart, prevEnd))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TotalRevenueComparison{
+		Compare:           mode,
+		CurrentPeriod:     models.Period{StartDate: filter.StartDate, EndDate: filter.EndDate},
+		ComparisonPeriod:  models.Period{StartDate: prevStart, EndDate: prevEnd},
+		RevenueDefinition: filter.Revenue,
+		RevenueDelta:      newRevenueDelta(current.TotalRevenue, previous.TotalRevenue),
+	}, nil
+}
+
+// CompareRevenueByProduct returns a page of product revenue alongside the comparison range
+func (s *RevenueService) CompareRevenueByProduct(filter models.RevenueFilter, page models.PageRequest, mode string) (*models.RevenueComparison[models.ProductRevenueComparison], error) {
+	return compareBreakdown(filter, page, mode, s.GetRevenueByProduct,
+		func(r models.ProductRevenue) string { return r.ProductID },
+		func(r models.ProductRevenue) float64 { return r.Revenue },
+		func(f models.RevenueFilter, keys []string) models.RevenueFilter { f.ProductIDs = keys; return f },
+		func(r models.ProductRevenue, d models.RevenueDelta) models.ProductRevenueComparison {
+			return models.ProductRevenueComparison{ProductID: r.ProductID, ProductName: r.ProductName, RevenueDelta: d}
+		})
+}
+
+// CompareRevenueByCategory returns a page of category revenue alongside the comparison range
+func (s *RevenueService) CompareRevenueByCategory(filter models.RevenueFilter, page models.PageRequest, mode string) (*models.RevenueComparison[models.CategoryRevenueComparison], error) {
+	return compareBreakdown(filter, page, mode, s.GetRevenueByCategory,
+		func(r models.CategoryRevenue) string { return r.Category },
+		func(r models.CategoryRevenue) float64 { return r.Revenue },
+		func(f models.RevenueFilter, keys []string) models.RevenueFilter { f.Categories = keys; return f },
+		func(r models.CategoryRevenue, d models.RevenueDelta) models.CategoryRevenueComparison {
+			return models.CategoryRevenueComparison{Category: r.Category, RevenueDelta: d}
+		})
+}
+
+// CompareRevenueByRegion returns a page of region revenue alongside the comparison range
+func (s *RevenueService) CompareRevenueByRegion(filter models.RevenueFilter, page models.PageRequest, mode string) (*models.RevenueComparison[models.RegionRevenueComparison], error) {
+	return compareBreakdown(filter, page, mode, s.GetRevenueByRegion,
+		func(r models.RegionRevenue) string { return r.Region },
+		func(r models.RegionRevenue) float64 { return r.Revenue },
+		func(f models.RevenueFilter, keys []string) models.RevenueFilter { f.Regions = keys; return f },
+		func(r models.RegionRevenue, d models.RevenueDelta) models.RegionRevenueComparison {
+			return models.RegionRevenueComparison{Region: r.Region, RevenueDelta: d}
+		})
+}
+
+// compareBreakdown pages a breakdown over the requested range, then looks up the same rows in
+// the comparison range. Rows without comparison revenue are compared against zero. Rows with
+// revenue only in the comparison range are part of the page when zero rows are included, and
+// are left out otherwise.
+func compareBreakdown[R any, C any](
+	filter models.RevenueFilter,
+	page models.PageRequest,
+	mode string,
+	query func(models.RevenueFilter, models.PageRequest) (*models.Page[R], error),
+	key func(R) string,
+	revenue func(R) float64,
+	restrict func(models.RevenueFilter, []string) models.RevenueFilter,
+	build func(R, models.RevenueDelta) C,
+) (*models.RevenueComparison[C], error) {
+	prevStart, prevEnd, err := ComparisonRange(filter.StartDate, filter.EndDate, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := query(filter, page)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the rows on the current page need a comparison value
+	previousByKey := make(map[string]float64, len(current.Data))
+	if len(current.Data) > 0 {
+		keys := make([]string, 0, len(current.Data))
+		for _, row := range current.Data {
+			keys = append(keys, key(row))
+		}
+		previous, err := query(restrict(filter.WithDates(prevStart, prevEnd), keys), models.PageRequest{IncludeZero: false})
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range previous.Data {
+			previousByKey[key(row)] = revenue(row)
+		}
+	}
+
+	rows := make([]C, 0, len(current.Data))
+	for _, row := range current.Data {
+		rows = append(rows, build(row, newRevenueDelta(revenue(row), previousByKey[key(row)])))
+	}
+
+	return &models.RevenueComparison[C]{
+		Compare:              mode,
+		CurrentPeriod:        models.Period{StartDate: filter.StartDate, EndDate: filter.EndDate},
+		ComparisonPeriod:     models.Period{StartDate: prevStart, EndDate: prevEnd},
+		IncludesPreviousOnly: page.IncludeZero,
+		Page: models.Page[C]{
+			Data:              rows,
+			Total:             current.Total,
+			Limit:             current.Limit,
+			Offset:            current.Offset,
+			NextCursor:        current.NextCursor,
+			RevenueDefinition: current.RevenueDefinition,
+		},
+	}, nil
+}
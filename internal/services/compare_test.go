@@ -0,0 +1,53 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComparisonRange(t *testing.T) {
+	date := func(value string) time.Time {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			t.Fatalf("parsing %s: %v", value, err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name      string
+		start     string
+		end       string
+		mode      string
+		wantStart string
+		wantEnd   string
+		wantErr   bool
+	}{
+		{name: "previous period of a month", start: "2024-03-01", end: "2024-03-31", mode: ComparePreviousPeriod, wantStart: "2024-01-30", wantEnd: "2024-02-29"},
+		{name: "previous period of a day", start: "2024-03-01", end: "2024-03-01", mode: ComparePreviousPeriod, wantStart: "2024-02-29", wantEnd: "2024-02-29"},
+		{name: "previous period across a year", start: "2024-01-01", end: "2024-01-07", mode: ComparePreviousPeriod, wantStart: "2023-12-25", wantEnd: "2023-12-31"},
+		{name: "previous year", start: "2024-03-01", end: "2024-03-31", mode: ComparePreviousYear, wantStart: "2023-03-01", wantEnd: "2023-03-31"},
+		// Go normalizes February 29th of a non-leap year to March 1st
+		{name: "previous year from a leap day", start: "2024-02-29", end: "2024-02-29", mode: ComparePreviousYear, wantStart: "2023-03-01", wantEnd: "2023-03-01"},
+		{name: "unsupported mode", start: "2024-03-01", end: "2024-03-31", mode: "previous_month", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := ComparisonRange(date(tt.start), date(tt.end), tt.mode)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ComparisonRange(%s, %s, %s) succeeded, want an error", tt.start, tt.end, tt.mode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ComparisonRange(%s, %s, %s): %v", tt.start, tt.end, tt.mode, err)
+			}
+			if !start.Equal(date(tt.wantStart)) || !end.Equal(date(tt.wantEnd)) {
+				t.Errorf("ComparisonRange(%s, %s, %s) = %s..%s, want %s..%s", tt.start, tt.end, tt.mode,
+					start.Format("2006-01-02"), end.Format("2006-01-02"), tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"sales-analytics/internal/export"
+	"sales-analytics/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type CustomerAnalyticsService struct {
+	db *gorm.DB
+}
+
+func NewCustomerAnalyticsService(db *gorm.DB) *CustomerAnalyticsService {
+	return &CustomerAnalyticsService{db: db}
+}
+
+// GetTopCustomers returns a page of customers with their revenue, order count and average
+// order value over the filtered range
+func (s *CustomerAnalyticsService) GetTopCustomers(filter models.RevenueFilter, page models.PageRequest) (*models.Page[models.CustomerRevenue], error) {
+	results, err := paginate[models.CustomerRevenue](s.db, s.customerBreakdown(filter, page), breakdownColumns{key: "customer_id", name: "customer_name"}, page)
+	if err != nil {
+		return nil, fmt.Errorf("error querying top customers: %v", err)
+	}
+	results.RevenueDefinition = &filter.Revenue
+
+	return results, nil
+}
+
+// TopCustomersTable returns the requested page of top customers as an export table streamed from
+// the database
+func (s *CustomerAnalyticsService) TopCustomersTable(filter models.RevenueFilter, page models.PageRequest) (*export.Table, error) {
+	return pageTable[models.CustomerRevenue](s.db, s.customerBreakdown(filter, page), breakdownColumns{key: "customer_id", name: "customer_name"}, page)
+}
+
+// customerBreakdown selects the revenue, orders and quantity of every customer matching the filter
+func (s *CustomerAnalyticsService) customerBreakdown(filter models.RevenueFilter, page models.PageRequest) func() *gorm.DB {
+	return func() *gorm.DB {
+		sales := filteredOrders(s.db, filter).
+			Select("orders.customer_id, SUM(" + revenueExpr(filter.Revenue) + ") as revenue, COUNT(*) as order_count, SUM(orders.quantity) as quantity").
+			Group("orders.customer_id")
+
+		query := s.db.Model(&models.Customer{}).
+			Select("customers.customer_id, customers.name as customer_name, customers.region, "+
+				"COALESCE(sales.revenue, 0) as revenue, COALESCE(sales.order_count, 0) as order_count, "+
+				"COALESCE(sales.quantity, 0) as quantity, COALESCE(sales.revenue / sales.order_count, 0) as average_order_value").
+			Joins("LEFT JOIN (?) sales ON sales.customer_id = customers.customer_id", sales).
+			Scopes(customerFilter(filter))
+		if !page.IncludeZero {
+			query = query.Where("sales.customer_id IS NOT NULL")
+		}
+		return query
+	}
+}
+
+// GetCustomerSummary returns the lifetime purchase history of a customer
+func (s *CustomerAnalyticsService) GetCustomerSummary(customerID string, revenue models.RevenueDefinition) (*models.CustomerSummary, error) {
+	var customer models.Customer
+	if err := s.db.Where("customer_id = ?", customerID).First(&customer).Error; err != nil {
+		return nil, err
+	}
+
+	var purchases struct {
+		FirstPurchase   *time.Time
+		LastPurchase    *time.Time
+		OrderCount      int64
+		LifetimeRevenue float64
+	}
+
+	err := s.db.Model(&models.Order{}).
+		Select("MIN(orders.date_of_sale) as first_purchase, MAX(orders.date_of_sale) as last_purchase, "+
+			"COUNT(*) as order_count, COALESCE(SUM("+revenueExpr(revenue)+"), 0) as lifetime_revenue").
+		Where("orders.customer_id = ?", customerID).
+		Scan(&purchases).Error
+	if err != nil {
+		return nil, fmt.Errorf("error querying customer purchases: %v", err)
+	}
+
+	summary := &models.CustomerSummary{
+		CustomerID:      customer.CustomerID,
+		Name:            customer.Name,
+		Email:           customer.Email,
+		Region:          customer.Region,
+		FirstPurchase:   purchases.FirstPurchase,
+		LastPurchase:    purchases.LastPurchase,
+		OrderCount:      purchases.OrderCount,
+		LifetimeRevenue: purchases.LifetimeRevenue,
+
+		RevenueDefinition: revenue,
+	}
+
+	if summary.OrderCount > 0 {
+		summary.AverageOrderValue = summary.LifetimeRevenue / float64(summary.OrderCount)
+
+		// The favourite category is the one the customer spent the most on
+		var favourite []string
+		err := s.db.Model(&models.Order{}).
+			Joins("JOIN products ON products.product_id = orders.product_id").
+			Where("orders.customer_id = ?", customerID).
+			Group("products.category").
+			Order("SUM("+revenueExpr(revenue)+") DESC, products.category").
+			Limit(1).
+			Pluck("products.category", &favourite).Error
+		if err != nil {
+			return nil, fmt.Errorf("error querying favourite category: %v", err)
+		}
+		if len(favourite) > 0 {
+			summary.FavouriteCategory = favourite[0]
+		}
+	}
+
+	return summary, nil
+}
+
+// GetCustomerCounts counts the customers who ordered within the filtered range and splits them
+// into new customers (first ever purchase in the range) and returning customers
+func (s *CustomerAnalyticsService) GetCustomerCounts(filter models.RevenueFilter) (*models.CustomerCounts, error) {
+	active := filteredOrders(s.db, filter).
+		Distinct("orders.customer_id")
+
+	firstPurchases := s.db.Model(&models.Order{}).
+		Select("orders.customer_id, MIN(orders.date_of_sale) as first_purchase").
+		Group("orders.customer_id")
+
+	counts := &models.CustomerCounts{
+		StartDate: filter.StartDate,
+		EndDate:   filter.EndDate,
+	}
+
+	err := s.db.Table("(?) active", active).
+		Select("COUNT(*) as active, COUNT(*) FILTER (WHERE first_purchases.first_purchase >= ?) as new", filter.StartDate).
+		Joins("JOIN (?) first_purchases ON first_purchases.customer_id = active.customer_id", firstPurchases).
+		Scan(counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("error counting customers: %v", err)
+	}
+
+	counts.Returning = counts.Active - counts.New
+	return counts, nil
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"sales-analytics/internal/export"
+	"sales-analytics/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// componentColumn is one aggregate of models.RevenueComponents
+type componentColumn struct {
+	column string
+	expr   string
+}
+
+// componentColumns lists the aggregates making up models.RevenueComponents under a revenue
+// definition
+func componentColumns(def models.RevenueDefinition) []componentColumn {
+	revenue := revenueExpr(def)
+	return []componentColumn{
+		{"gross_sales", "SUM(" + grossSalesExpr + ")"},
+		{"total_discount", "SUM(" + discountExpr(def) + ")"},
+		{"shipping_revenue", "SUM(orders.shipping_cost)"},
+		{"revenue", "SUM(" + revenue + ")"},
+		{"quantity", "SUM(orders.quantity)"},
+		{"order_count", "COUNT(*)"},
+		{"discounted_orders", "COUNT(*) FILTER (WHERE orders.discount > 0)"},
+		{"discounted_gross_sales", "SUM(" + grossSalesExpr + ") FILTER (WHERE orders.discount > 0)"},
+		{"discounted_revenue", "SUM(" + revenue + ") FILTER (WHERE orders.discount > 0)"},
+		{"full_price_orders", "COUNT(*) FILTER (WHERE orders.discount <= 0)"},
+		{"full_price_gross_sales", "SUM(" + grossSalesExpr + ") FILTER (WHERE orders.discount <= 0)"},
+		{"full_price_revenue", "SUM(" + revenue + ") FILTER (WHERE orders.discount <= 0)"},
+	}
+}
+
+// decompositionColumns are the columns a decomposition breakdown is sorted by
+var decompositionColumns = breakdownColumns{key: "key", name: "label"}
+
+// IsValidDecompositionGroup reports whether groupBy is a supported revenue decomposition grouping
+func IsValidDecompositionGroup(groupBy string) bool {
+	_, ok := seriesGroups[groupBy]
+	return ok
+}
+
+// GetRevenueDecomposition splits revenue into gross sales, discounts and shipping. When groupBy
+// is set, a page of the decomposition per product, category or region is included.
+func (s *RevenueService) GetRevenueDecomposition(filter models.RevenueFilter, groupBy string, page models.PageRequest) (*models.RevenueDecomposition, error) {
+	columns := componentColumns(filter.Revenue)
+	result := &models.RevenueDecomposition{GroupBy: groupBy, RevenueDefinition: filter.Revenue}
+
+	err := filteredOrders(s.db, filter).
+		Select(aggregateComponents(columns)).
+		Scan(&result.Total).Error
+	if err != nil {
+		return nil, fmt.Errorf("error calculating revenue decomposition: %v", err)
+	}
+	result.Total.DiscountRate = discountRate(result.Total)
+
+	if groupBy == "" {
+		return result, nil
+	}
+
+	build, err := s.decompositionBreakdown(filter, groupBy, page)
+	if err != nil {
+		return nil, err
+	}
+
+	breakdown, err := paginate[models.RevenueComponentsRow](s.db, build, decompositionColumns, page)
+	if err != nil {
+		return nil, fmt.Errorf("error querying revenue decomposition by %s: %v", groupBy, err)
+	}
+	for i := range breakdown.Data {
+		breakdown.Data[i].DiscountRate = discountRate(breakdown.Data[i].RevenueComponents)
+	}
+	result.Breakdown = breakdown
+
+	return result, nil
+}
+
+// RevenueDecompositionTable returns the requested page of the decomposition per product,
+// category or region as an export table streamed from the database
+func (s *RevenueService) RevenueDecompositionTable(filter models.RevenueFilter, groupBy string, page models.PageRequest) (*export.Table, error) {
+	build, err := s.decompositionBreakdown(filter, groupBy, page)
+	if err != nil {
+		return nil, err
+	}
+
+	return export.FromRows(func(yield func(models.RevenueComponentsRow) error) error {
+		return streamPage(s.db, build, decompositionColumns, page, func(row models.RevenueComponentsRow) error {
+			row.DiscountRate = discountRate(row.RevenueComponents)
+			return yield(row)
+		})
+	})
+}
+
+// decompositionBreakdown selects the revenue components of every product, category or region
+// matching the filter
+func (s *RevenueService) decompositionBreakdown(filter models.RevenueFilter, groupBy string, page models.PageRequest) (func() *gorm.DB, error) {
+	group, ok := seriesGroups[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported decomposition group '%s'", groupBy)
+	}
+	columns := componentColumns(filter.Revenue)
+
+	return func() *gorm.DB {
+		sales := filteredOrders(s.db, filter).
+			Select(group.keyColumn + " as key, " + aggregateComponents(columns)).
+			Group(group.keyColumn)
+
+		query := s.db.Table("(?) dimensions", s.decompositionDimensions(filter, groupBy)).
+			Select("dimensions.key, dimensions.label, "+joinedComponents("sales", columns)).
+			Joins("LEFT JOIN (?) sales ON sales.key = dimensions.key", sales)
+		if !page.IncludeZero {
+			query = query.Where("sales.key IS NOT NULL")
+		}
+		return query
+	}, nil
+}
+
+// decompositionDimensions lists every product, category or region matching the filter so rows
+// without sales can be reported
+func (s *RevenueService) decompositionDimensions(filter models.RevenueFilter, groupBy string) *gorm.DB {
+	switch groupBy {
+	case GroupByProduct:
+		return s.db.Model(&models.Product{}).
+			Select("products.product_id as key, products.name as label").
+			Scopes(productFilter(filter))
+	case GroupByCategory:
+		return s.db.Model(&models.Product{}).
+			Distinct("products.category as key, products.category as label").
+			Scopes(productFilter(filter))
+	default:
+		return s.db.Model(&models.Customer{}).
+			Distinct("customers.region as key, customers.region as label").
+			Scopes(customerFilter(filter))
+	}
+}
+
+// aggregateComponents selects every revenue component over the current group of orders
+func aggregateComponents(columns []componentColumn) string {
+	selects := make([]string, len(columns))
+	for i, c := range columns {
+		selects[i] = fmt.Sprintf("COALESCE(%s, 0) as %s", c.expr, c.column)
+	}
+	return strings.Join(selects, ", ")
+}
+
+// joinedComponents selects every revenue component from an aggregated subquery, reporting
+// zero for rows the subquery has no match for
+func joinedComponents(alias string, columns []componentColumn) string {
+	selects := make([]string, len(columns))
+	for i, c := range columns {
+		selects[i] = fmt.Sprintf("COALESCE(%s.%s, 0) as %s", alias, c.column, c.column)
+	}
+	return strings.Join(selects, ", ")
+}
+
+// discountRate returns the share of gross sales given away as discounts
+func discountRate(c models.RevenueComponents) float64 {
+	if c.GrossSales == 0 {
+		return 0
+	}
+	return c.TotalDiscount / c.GrossSales
+}
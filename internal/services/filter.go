@@ -0,0 +1,54 @@
+package services
+
+import (
+	"sales-analytics/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// filteredOrders returns orders joined to their product and customer, restricted by the filter
+func filteredOrders(db *gorm.DB, f models.RevenueFilter) *gorm.DB {
+	query := db.Model(&models.Order{}).
+		Joins("JOIN products ON products.product_id = orders.product_id").
+		Joins("JOIN customers ON customers.customer_id = orders.customer_id").
+		Where("orders.date_of_sale BETWEEN ? AND ?", f.StartDate, f.EndDate).
+		Scopes(productFilter(f), customerFilter(f))
+
+	if len(f.PaymentMethods) > 0 {
+		query = query.Where("orders.payment_method IN ?", f.PaymentMethods)
+	}
+	if f.MinQuantity != nil {
+		query = query.Where("orders.quantity >= ?", *f.MinQuantity)
+	}
+	if f.MaxQuantity != nil {
+		query = query.Where("orders.quantity <= ?", *f.MaxQuantity)
+	}
+
+	return query
+}
+
+// productFilter restricts a query that joins products to the filtered categories and product IDs
+func productFilter(f models.RevenueFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(f.Categories) > 0 {
+			db = db.Where("products.category IN ?", f.Categories)
+		}
+		if len(f.ProductIDs) > 0 {
+			db = db.Where("products.product_id IN ?", f.ProductIDs)
+		}
+		return db
+	}
+}
+
+// customerFilter restricts a query that joins customers to the filtered regions and customer IDs
+func customerFilter(f models.RevenueFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(f.Regions) > 0 {
+			db = db.Where("customers.region IN ?", f.Regions)
+		}
+		if len(f.CustomerIDs) > 0 {
+			db = db.Where("customers.customer_id IN ?", f.CustomerIDs)
+		}
+		return db
+	}
+}
@@ -2,9 +2,11 @@ package services
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,27 +17,72 @@ import (
 	"gorm.io/gorm/clause"
 )
 
+// ErrLoadInProgress is returned when a refresh is requested while another one is running
+var ErrLoadInProgress = errors.New("data refresh is already in progress, please try again later")
+
 type LoadStatus struct {
 	IsLoading    bool      `json:"is_loading"`
+	JobID        uint      `json:"job_id,omitempty"`
 	StartTime    time.Time `json:"start_time,omitempty"`
 	RecordsRead  int       `json:"records_read"`
 	LastError    string    `json:"last_error,omitempty"`
 	LastComplete time.Time `json:"last_complete,omitempty"`
 }
 
+// LoadOptions controls how a refresh applies the CSV file to the orders table
+type LoadOptions struct {
+	// ForceFull reloads the whole file instead of only the rows appended since the last refresh
+	ForceFull bool
+	// Reconcile updates orders whose fields changed in the file instead of skipping them
+	Reconcile bool
+	// DeleteMissing soft-deletes orders absent from the file. It implies a full, reconciling load.
+	DeleteMissing bool
+	// Atomic loads the file into staging tables and only merges it into the live tables once
+	// every row has been loaded and the totals check out, instead of committing batch by batch
+	Atomic bool
+	// MaxDrop is the largest share of rows an atomic load may reject, and of the live orders and
+	// gross sales a full atomic load deleting missing orders may remove, before it is refused
+	MaxDrop float64
+}
+
+// loadResult holds the row counters collected while processing a CSV file
+type loadResult struct {
+	rowsRead     int
+	rowsInserted int
+	rowsUpdated  int
+	rowsSkipped  int
+	rowsRejected int
+	rowsDeleted  int
+
+	unmappedColumns string
+
+	mode        string
+	startOffset int64
+}
+
 type LoaderService struct {
 	db          *gorm.DB
 	logger      *logrus.Logger
 	loadingLock sync.Mutex
 	status      LoadStatus
 	batchSize   int
+	rejects     *RejectStore
+	columns     ColumnMapping
+	rollups     *RollupService
+
+	// version and onRefresh are guarded by loadingLock
+	version   models.DataVersion
+	onRefresh []func(models.DataVersion)
 }
 
-func NewLoaderService(db *gorm.DB, logger *logrus.Logger, batchSize int) *LoaderService {
+func NewLoaderService(db *gorm.DB, logger *logrus.Logger, batchSize int, columns ColumnMapping, rollups *RollupService) *LoaderService {
 	return &LoaderService{
 		db:        db,
 		logger:    logger,
 		batchSize: batchSize,
+		rejects:   NewRejectStore(db, batchSize),
+		columns:   columns,
+		rollups:   rollups,
 	}
 }
 
@@ -53,137 +100,431 @@ func (s *LoaderService) IsLoading() bool {
 	return s.status.IsLoading
 }
 
-// LoadData initiates the data loading process in the background
-func (s *LoaderService) LoadData(csvPath string) error {
+// DataVersion returns the version of the currently loaded data
+func (s *LoaderService) DataVersion() models.DataVersion {
+	s.loadingLock.Lock()
+	defer s.loadingLock.Unlock()
+	return s.version
+}
+
+// OnRefresh registers fn to be called with the new data version whenever a refresh finishes
+// and changed the data
+func (s *LoaderService) OnRefresh(fn func(models.DataVersion)) {
+	s.loadingLock.Lock()
+	defer s.loadingLock.Unlock()
+	s.onRefresh = append(s.onRefresh, fn)
+}
+
+// LoadDataVersion restores the data version from the last finished refresh job that changed the
+// data: a completed one, or a failed one that committed some rows
+func (s *LoaderService) LoadDataVersion() error {
+	var job models.RefreshJob
+	err := s.db.Where("finished_at IS NOT NULL").
+		Where("status = ? OR rows_inserted + rows_updated + rows_deleted > 0", models.RefreshStatusCompleted).
+		Order("finished_at DESC, id DESC").Limit(1).Find(&job).Error
+	if err != nil {
+		return fmt.Errorf("error loading data version: %v", err)
+	}
+
+	s.loadingLock.Lock()
+	defer s.loadingLock.Unlock()
+	if job.ID != 0 {
+		s.version = models.DataVersion{Version: job.ID, UpdatedAt: *job.FinishedAt}
+	}
+	return nil
+}
+
+// GetJob returns the refresh job with the given ID
+func (s *LoaderService) GetJob(id uint) (*models.RefreshJob, error) {
+	var job models.RefreshJob
+	if err := s.db.First(&job, id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListJobs returns the most recent refresh jobs, newest first
+func (s *LoaderService) ListJobs(limit, offset int) ([]models.RefreshJob, int64, error) {
+	var (
+		jobs  []models.RefreshJob
+		total int64
+	)
+
+	if err := s.db.Model(&models.RefreshJob{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("error counting refresh jobs: %v", err)
+	}
+
+	if err := s.db.Order("id DESC").Limit(limit).Offset(offset).Find(&jobs).Error; err != nil {
+		return nil, 0, fmt.Errorf("error listing refresh jobs: %v", err)
+	}
+
+	return jobs, total, nil
+}
+
+// ForEachRejectedRow streams the rows rejected by a refresh job in line order
+func (s *LoaderService) ForEachRejectedRow(jobID uint, fn func(models.RejectedRow) error) error {
+	return s.rejects.ForEach(jobID, fn)
+}
+
+// FailInterruptedJobs marks jobs left running by a previous process as failed
+func (s *LoaderService) FailInterruptedJobs() error {
+	return s.db.Model(&models.RefreshJob{}).
+		Where("status = ?", models.RefreshStatusRunning).
+		Updates(map[string]interface{}{
+			"status":      models.RefreshStatusFailed,
+			"finished_at": time.Now(),
+			"error":       "refresh interrupted by application restart",
+		}).Error
+}
+
+// LoadData records a new refresh job and starts the data loading process in the background.
+// Only rows appended since the previous refresh are loaded unless a full load is requested or
+// the file has been rewritten.
+func (s *LoaderService) LoadData(csvPath, trigger string, opts LoadOptions) (*models.RefreshJob, error) {
+	if opts.DeleteMissing {
+		// Only the whole file tells which orders are gone, and orders that come back must be restored
+		opts.ForceFull = true
+		opts.Reconcile = true
+	}
+
 	s.loadingLock.Lock()
 	if s.status.IsLoading {
 		s.loadingLock.Unlock()
-		return fmt.Errorf("data refresh is already in progress, please try again later")
+		return nil, ErrLoadInProgress
+	}
+
+	job := &models.RefreshJob{
+		Trigger:       trigger,
+		Status:        models.RefreshStatusRunning,
+		CSVPath:       csvPath,
+		Reconcile:     opts.Reconcile,
+		DeleteMissing: opts.DeleteMissing,
+		Atomic:        opts.Atomic,
+		StartedAt:     time.Now(),
+	}
+	if err := s.db.Create(job).Error; err != nil {
+		s.loadingLock.Unlock()
+		return nil, fmt.Errorf("error creating refresh job: %v", err)
 	}
+
 	s.status = LoadStatus{
-		IsLoading:   true,
-		StartTime:   time.Now(),
-		RecordsRead: 0,
+		IsLoading:    true,
+		JobID:        job.ID,
+		StartTime:    job.StartedAt,
+		RecordsRead:  0,
+		LastComplete: s.status.LastComplete,
 	}
 	s.loadingLock.Unlock()
 
 	// Start the loading process in a goroutine
-	go func() {
-		if err := s.processCSV(csvPath); err != nil {
-			s.loadingLock.Lock()
-			s.status.LastError = err.Error()
-			s.status.IsLoading = false
-			s.loadingLock.Unlock()
-			s.logger.Errorf("Error loading data: %v", err)
-			return
+	go s.runJob(*job, opts)
+
+	return job, nil
+}
+
+// runJob processes the CSV file for the given job and persists the final outcome
+func (s *LoaderService) runJob(job models.RefreshJob, opts LoadOptions) {
+	result := &loadResult{}
+	err := s.processCSV(job.CSVPath, job.ID, opts, result)
+
+	// Appended batches refresh the rollup rows they touch as they are committed, and an atomic
+	// load rebuilds them in its merge. Reconciling loads can change orders anywhere, so the
+	// rollups are rebuilt once they succeed; a failed reconciling load may have committed some
+	// batches, so the rollups are set aside until the next rebuild.
+	committed := result.rowsInserted+result.rowsUpdated+result.rowsDeleted > 0
+	if err == nil && ((opts.Reconcile && !opts.Atomic) || !s.rollups.Ready()) {
+		if rollupErr := s.rollups.Refresh(); rollupErr != nil {
+			s.logger.Errorf("Error refreshing rollups for refresh job %d: %v", job.ID, rollupErr)
 		}
+	} else if err != nil && opts.Reconcile && !opts.Atomic && committed {
+		s.rollups.MarkStale()
+		s.logger.Warnf("Revenue queries read the orders table until the rollups are rebuilt after refresh job %d", job.ID)
+	}
 
-		s.loadingLock.Lock()
-		s.status.IsLoading = false
-		s.status.LastComplete = time.Now()
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	job.RowsRead = result.rowsRead
+	job.RowsInserted = result.rowsInserted
+	job.RowsUpdated = result.rowsUpdated
+	job.RowsSkipped = result.rowsSkipped
+	job.RowsRejected = result.rowsRejected
+	job.RowsDeleted = result.rowsDeleted
+	job.UnmappedColumns = result.unmappedColumns
+	job.Mode = result.mode
+	job.StartOffset = result.startOffset
+	if err != nil {
+		job.Status = models.RefreshStatusFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = models.RefreshStatusCompleted
+	}
+
+	if saveErr := s.db.Save(&job).Error; saveErr != nil {
+		s.logger.Errorf("Error saving refresh job %d: %v", job.ID, saveErr)
+	}
+
+	// A successful job is a new data version, and so is a failed direct load that committed
+	// some batches. A failed atomic load never got to merge its rows, so nothing changed. The
+	// version is only bumped here, after the merge and its rollups have been committed.
+	changed := err == nil || (!opts.Atomic && committed)
+	version := models.DataVersion{Version: job.ID, UpdatedAt: finishedAt}
+
+	s.loadingLock.Lock()
+	s.status.IsLoading = false
+	if err != nil {
+		s.status.LastError = err.Error()
+	} else {
+		s.status.LastComplete = finishedAt
 		s.status.LastError = ""
-		s.loadingLock.Unlock()
-		s.logger.Info("Data loaded successfully")
-	}()
+	}
+	var listeners []func(models.DataVersion)
+	if changed {
+		s.version = version
+		listeners = s.onRefresh
+	}
+	s.loadingLock.Unlock()
 
-	return nil
+	for _, fn := range listeners {
+		fn(version)
+	}
+
+	if err != nil {
+		s.logger.Errorf("Error loading data for refresh job %d: %v", job.ID, err)
+		return
+	}
+	s.logger.Infof("Data loaded successfully for refresh job %d", job.ID)
 }
 
-// processCSV handles the actual CSV processing
-func (s *LoaderService) processCSV(csvPath string) error {
+// updateJobProgress persists the running counters so pollers can follow a job in progress
+func (s *LoaderService) updateJobProgress(jobID uint, result *loadResult) {
+	if err := s.db.Model(&models.RefreshJob{}).Where("id = ?", jobID).Updates(map[string]interface{}{
+		"rows_read":        result.rowsRead,
+		"rows_inserted":    result.rowsInserted,
+		"rows_updated":     result.rowsUpdated,
+		"rows_skipped":     result.rowsSkipped,
+		"rows_rejected":    result.rowsRejected,
+		"rows_deleted":     result.rowsDeleted,
+		"unmapped_columns": result.unmappedColumns,
+		"mode":             result.mode,
+		"start_offset":     result.startOffset,
+	}).Error; err != nil {
+		s.logger.Warnf("Error updating progress of refresh job %d: %v", jobID, err)
+	}
+}
+
+// processCSV handles the actual CSV processing, resuming after the rows loaded by the previous
+// refresh when possible
+func (s *LoaderService) processCSV(csvPath string, jobID uint, opts LoadOptions, result *loadResult) error {
 	file, err := os.Open(csvPath)
 	if err != nil {
 		return fmt.Errorf("error opening CSV file: %v", err)
 	}
 	defer file.Close()
 
-	reader := csv.NewReader(file)
-	// Skip header row
-	_, err = reader.Read()
+	info, err := file.Stat()
 	if err != nil {
-		return fmt.Errorf("error reading CSV header: %v", err)
+		return fmt.Errorf("error reading CSV file: %v", err)
+	}
+
+	plan, err := s.planLoad(file, csvPath, info.Size(), opts.ForceFull)
+	if err != nil {
+		return err
+	}
+	result.mode = plan.mode
+	result.startOffset = plan.offset
+	if plan.reason != "" {
+		s.logger.Infof("Loading the whole CSV file for refresh job %d: %s", jobID, plan.reason)
+	} else {
+		s.logger.Infof("Loading CSV rows after byte %d for refresh job %d", plan.offset, jobID)
+	}
+
+	// Line numbers reported by the reader are relative to where it starts reading
+	reader := csv.NewReader(io.NewSectionReader(file, plan.offset, info.Size()-plan.offset))
+	// Column counts are checked per row by the validator
+	reader.FieldsPerRecord = -1
+	header := plan.header
+	line := plan.line
+	if header == nil {
+		header, err = reader.Read()
+		if err != nil {
+			return fmt.Errorf("error reading CSV header: %v", err)
+		}
+		line = recordEndLine(reader, 0, header)
+	}
+
+	// Map columns by header name before any row is written
+	cols, unmapped, err := s.columns.resolve(header)
+	if err != nil {
+		return err
+	}
+	if len(unmapped) > 0 {
+		s.logger.Warnf("Ignoring unmapped CSV columns for refresh job %d: %s", jobID, strings.Join(unmapped, ", "))
+		result.unmappedColumns = strings.Join(unmapped, ",")
 	}
 
 	var (
 		customerMap = make(map[string]models.Customer)
 		productMap  = make(map[string]models.Product)
+		priceMap    = make(dailyPrices)
 		orders      []models.Order
-		record      []string
-		recordCount int
+		rejects     []models.RejectedRow
+		seen        *seenOrders
 	)
+	if opts.DeleteMissing {
+		seen = newSeenOrders()
+	}
+	if opts.Atomic {
+		if err := s.createStagingTables(); err != nil {
+			return err
+		}
+		defer func() {
+			if err := s.dropStagingTables(); err != nil {
+				s.logger.Warnf("Error cleaning up after refresh job %d: %v", jobID, err)
+			}
+		}()
+	}
+
+	flush := func() error {
+		if err := s.rejects.Save(rejects); err != nil {
+			return err
+		}
+		if len(orders) > 0 {
+			var err error
+			if opts.Atomic {
+				err = s.stageBatch(mapToSlice(customerMap), mapToSlice(productMap), mapToSlice(priceMap), orders)
+			} else {
+				err = s.processBatch(mapToSlice(customerMap), mapToSlice(productMap), mapToSlice(priceMap), orders, opts.Reconcile, result)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		// Everything read so far has been written, so the next refresh can resume from here.
+		// Staged rows only count once they have been merged.
+		if !opts.Atomic {
+			if err := s.saveLoadState(s.db, file, csvPath, header, plan.offset+reader.InputOffset(), line); err != nil {
+				return err
+			}
+		}
+		s.updateJobProgress(jobID, result)
+		orders = orders[:0]
+		rejects = rejects[:0]
+		customerMap = make(map[string]models.Customer)
+		productMap = make(map[string]models.Product)
+		priceMap = make(dailyPrices)
+		return nil
+	}
 
 	for {
-		record, err = reader.Read()
-		if err != nil {
-			break // End of file
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
 		}
 
-		recordCount++
+		result.rowsRead++
 		s.loadingLock.Lock()
-		s.status.RecordsRead = recordCount
+		s.status.RecordsRead = result.rowsRead
 		s.loadingLock.Unlock()
 
-		// Parse numeric values
-		quantity, _ := strconv.Atoi(record[7])                // Quantity Sold
-		unitPrice, _ := strconv.ParseFloat(record[8], 64)     // Unit Price
-		discount, _ := strconv.ParseFloat(record[9], 64)      // Discount
-		shippingCost, _ := strconv.ParseFloat(record[10], 64) // Shipping Cost
-
-		// Parse date
-		date, err := time.Parse("2006-01-02", record[6]) // Date of Sale
-		if err != nil {
-			return fmt.Errorf("error parsing date: %v", err)
-		}
-
-		// Update customer map (last record wins for duplicates)
-		customerMap[record[2]] = models.Customer{
-			CustomerID: record[2],
-			Name:       record[12],
-			Email:      record[13],
-			Address:    record[14],
-			Region:     record[5],
+		// Track where the record ends so the next incremental load numbers its lines correctly
+		if err == nil {
+			line = recordEndLine(reader, plan.line, record)
 		}
 
-		// Update product map (last record wins for duplicates)
-		productMap[record[1]] = models.Product{
-			ProductID: record[1],
-			Name:      record[3],
-			Category:  record[4],
-			UnitPrice: unitPrice,
+		if err != nil {
+			// Malformed CSV (e.g. unbalanced quotes) only affects the current record
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return fmt.Errorf("error reading CSV: %v", err)
+			}
+			rejects = append(rejects, models.RejectedRow{
+				JobID:      jobID,
+				LineNumber: plan.line + parseErr.StartLine,
+				RawRow:     encodeRecord(record),
+				Reason:     parseErr.Err.Error(),
+			})
+			result.rowsRejected++
+			line = plan.line + parseErr.Line
+			if seen != nil {
+				seen.addRejected(record, cols)
+			}
+		} else if row, err := validateRecord(record, cols); err != nil {
+			start, _ := reader.FieldPos(0)
+			rejects = append(rejects, models.RejectedRow{
+				JobID:      jobID,
+				LineNumber: plan.line + start,
+				RawRow:     encodeRecord(record),
+				Reason:     err.Error(),
+			})
+			result.rowsRejected++
+			if seen != nil {
+				seen.addRejected(record, cols)
+			}
+		} else {
+			// Last record wins for duplicate customers and products
+			customerMap[row.customer.CustomerID] = row.customer
+			productMap[row.product.ProductID] = row.product
+			// One price per product and day of sale; only changes reach the price history
+			priceMap.add(row.order)
+			orders = append(orders, row.order)
+			if seen != nil {
+				seen.add(row.order.OrderID)
+			}
 		}
 
-		orders = append(orders, models.Order{
-			OrderID:       record[0],
-			ProductID:     record[1],
-			CustomerID:    record[2],
-			DateOfSale:    date,
-			Quantity:      quantity,
-			Discount:      discount,
-			ShippingCost:  shippingCost,
-			PaymentMethod: record[11],
-		})
-
 		// Process in batches
-		if len(orders) >= s.batchSize {
-			if err := s.processBatch(mapToSlice(customerMap), mapToSlice(productMap), orders); err != nil {
+		if len(orders)+len(rejects) >= s.batchSize {
+			if err := flush(); err != nil {
 				return err
 			}
-			orders = orders[:0]
-			customerMap = make(map[string]models.Customer)
-			productMap = make(map[string]models.Product)
 		}
 	}
 
 	// Process remaining records
-	if len(orders) > 0 {
-		if err := s.processBatch(mapToSlice(customerMap), mapToSlice(productMap), orders); err != nil {
+	if err := flush(); err != nil {
+		return err
+	}
+
+	if opts.Atomic {
+		if err := s.validateStaging(opts, result); err != nil {
+			return err
+		}
+		saveState := func(tx *gorm.DB) error {
+			return s.saveLoadState(tx, file, csvPath, header, plan.offset+reader.InputOffset(), line)
+		}
+		if err := s.mergeStaging(opts, seen, saveState, result); err != nil {
 			return err
 		}
+		s.updateJobProgress(jobID, result)
+		return nil
 	}
 
+	if seen == nil {
+		return nil
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		deleted, err := s.deleteMissingOrders(tx, seen)
+		result.rowsDeleted = deleted
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.updateJobProgress(jobID, result)
 	return nil
 }
 
+// recordEndLine returns the line the record just read ends on, counting from base. Quoted fields
+// may span several lines.
+func recordEndLine(reader *csv.Reader, base int, record []string) int {
+	start, _ := reader.FieldPos(0)
+	end := base + start
+	for _, field := range record {
+		end += strings.Count(field, "\n")
+	}
+	return end
+}
+
 // mapToSlice converts a map to a slice
 func mapToSlice[T any](m map[string]T) []T {
 	result := make([]T, 0, len(m))
@@ -193,32 +534,89 @@ func mapToSlice[T any](m map[string]T) []T {
 	return result
 }
 
-func (s *LoaderService) processBatch(customers []models.Customer, products []models.Product, orders []models.Order) error {
-	return s.db.Transaction(func(tx *gorm.DB) error {
+// customerUpsert overwrites existing customers with the latest CSV values
+func customerUpsert() clause.OnConflict {
+	return clause.OnConflict{
+		Columns:   []clause.Column{{Name: "customer_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "email", "address", "region"}),
+	}
+}
+
+// productUpsert overwrites existing products with the latest CSV values
+func productUpsert() clause.OnConflict {
+	return clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "category", "unit_price"}),
+	}
+}
+
+// priceUpsert keeps the last price seen for each product and day
+func priceUpsert() clause.OnConflict {
+	return clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}, {Name: "effective_date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"unit_price", "updated_at"}),
+	}
+}
+
+// orderInsert skips orders that already exist
+func orderInsert() clause.OnConflict {
+	return clause.OnConflict{
+		Columns:   []clause.Column{{Name: "order_id"}},
+		DoNothing: true,
+	}
+}
+
+// processBatch writes a batch in one transaction. Existing orders are skipped, or updated where
+// they changed when reconcile is set. Without reconcile, the rollup rows the batch touches are
+// refreshed in the same transaction.
+func (s *LoaderService) processBatch(customers []models.Customer, products []models.Product, prices []models.ProductPrice, orders []models.Order, reconcile bool, result *loadResult) error {
+	var inserted, updated int64
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var moved []string
+		if !reconcile {
+			var err error
+			if moved, err = s.rollups.trackBatch(tx, customers); err != nil {
+				return err
+			}
+		}
+
 		// Batch upsert customers
-		if err := tx.Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "customer_id"}},
-			DoUpdates: clause.AssignmentColumns([]string{"name", "email", "address", "region"}),
-		}).CreateInBatches(customers, s.batchSize).Error; err != nil {
+		if err := tx.Clauses(customerUpsert()).CreateInBatches(customers, s.batchSize).Error; err != nil {
 			return fmt.Errorf("error upserting customers: %v", err)
 		}
 
 		// Batch upsert products
-		if err := tx.Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "product_id"}},
-			DoUpdates: clause.AssignmentColumns([]string{"name", "category", "unit_price"}),
-		}).CreateInBatches(products, s.batchSize).Error; err != nil {
+		if err := tx.Clauses(productUpsert()).CreateInBatches(products, s.batchSize).Error; err != nil {
 			return fmt.Errorf("error upserting products: %v", err)
 		}
 
+		// Record the days product prices changed
+		if err := recordPrices(tx, prices); err != nil {
+			return err
+		}
+
+		if reconcile {
+			var err error
+			inserted, updated, err = reconcileOrders(tx, uniqueOrders(orders), s.batchSize)
+			return err
+		}
+
 		// Batch insert orders (skip if exists)
-		if err := tx.Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "order_id"}},
-			DoNothing: true,
-		}).CreateInBatches(orders, s.batchSize).Error; err != nil {
-			return fmt.Errorf("error creating orders: %v", err)
+		res := tx.Clauses(orderInsert()).CreateInBatches(orders, s.batchSize)
+		if res.Error != nil {
+			return fmt.Errorf("error creating orders: %v", res.Error)
 		}
+		inserted = res.RowsAffected
 
-		return nil
+		return s.rollups.refreshBatch(tx, moved, orders)
 	})
+	if err != nil {
+		return err
+	}
+
+	result.rowsInserted += int(inserted)
+	result.rowsUpdated += int(updated)
+	result.rowsSkipped += len(orders) - int(inserted) - int(updated)
+	return nil
 }
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sales-analytics/internal/export"
+	"sales-analytics/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const cursorPrefix = "offset:"
+
+// EncodeCursor returns the opaque cursor pointing at the given offset
+func EncodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+// DecodeCursor returns the offset a cursor points at
+func DecodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, fmt.Errorf("invalid cursor '%s'", cursor)
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), cursorPrefix))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid cursor '%s'", cursor)
+	}
+	return offset, nil
+}
+
+// breakdownColumns names the result columns a breakdown can be sorted by
+type breakdownColumns struct {
+	// key uniquely identifies a row and breaks ties so pages are stable
+	key string
+	// name is the human readable label used for sort=name
+	name string
+}
+
+// paginate counts the rows produced by build, then scans the requested page of them into dest.
+// build is called once per query because a gorm chain cannot be reused after execution.
+func paginate[T any](db *gorm.DB, build func() *gorm.DB, cols breakdownColumns, page models.PageRequest) (*models.Page[T], error) {
+	var total int64
+	if err := db.Table("(?) breakdown", build()).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	rows := []T{}
+	err := streamPage(db, build, cols, page, func(row T) error {
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.Page[T]{
+		Data:   rows,
+		Total:  total,
+		Limit:  page.Limit,
+		Offset: page.Offset,
+	}
+	if next := page.Offset + len(rows); page.Limit > 0 && int64(next) < total {
+		result.NextCursor = EncodeCursor(next)
+	}
+
+	return result, nil
+}
+
+// streamPage runs the query for the requested page of the rows produced by build and calls fn
+// for every row as it is read from the database. It stops at the first error from fn.
+func streamPage[T any](db *gorm.DB, build func() *gorm.DB, cols breakdownColumns, page models.PageRequest, fn func(T) error) error {
+	sortColumn := "revenue"
+	switch page.Sort {
+	case models.SortByQuantity:
+		sortColumn = "quantity"
+	case models.SortByName:
+		sortColumn = cols.name
+	}
+	direction := "ASC"
+	if page.Descending {
+		direction = "DESC"
+	}
+
+	orderBy := fmt.Sprintf("%s %s", sortColumn, direction)
+	if sortColumn != cols.key {
+		orderBy += fmt.Sprintf(", %s ASC", cols.key)
+	}
+
+	query := build().Order(orderBy)
+	if page.Limit > 0 {
+		query = query.Limit(page.Limit)
+	}
+	if page.Offset > 0 {
+		query = query.Offset(page.Offset)
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row T
+		if err := db.ScanRows(rows, &row); err != nil {
+			return fmt.Errorf("error reading row: %v", err)
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// pageTable returns the requested page of the rows produced by build as an export table whose
+// rows are streamed from the database, so downloads never hold the whole result in memory
+func pageTable[T any](db *gorm.DB, build func() *gorm.DB, cols breakdownColumns, page models.PageRequest) (*export.Table, error) {
+	return export.FromRows(func(yield func(T) error) error {
+		return streamPage(db, build, cols, page, yield)
+	})
+}
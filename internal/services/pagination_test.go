@@ -0,0 +1,60 @@
+package services
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, offset := range []int{0, 1, 100, 999999} {
+		cursor := EncodeCursor(offset)
+		got, err := DecodeCursor(cursor)
+		if err != nil {
+			t.Fatalf("DecodeCursor(EncodeCursor(%d)): %v", offset, err)
+		}
+		if got != offset {
+			t.Errorf("DecodeCursor(EncodeCursor(%d)) = %d", offset, got)
+		}
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name    string
+		cursor  string
+		want    int
+		wantErr bool
+	}{
+		{name: "valid", cursor: encode("offset:200"), want: 200},
+		{name: "zero offset", cursor: encode("offset:0"), want: 0},
+		{name: "not base64", cursor: "!!!", wantErr: true},
+		{name: "padded base64", cursor: base64.URLEncoding.EncodeToString([]byte("offset:2")), wantErr: true},
+		{name: "missing prefix", cursor: encode("200"), wantErr: true},
+		{name: "wrong prefix", cursor: encode("page:2"), wantErr: true},
+		{name: "negative offset", cursor: encode("offset:-1"), wantErr: true},
+		{name: "non-numeric offset", cursor: encode("offset:ten"), wantErr: true},
+		{name: "empty", cursor: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeCursor(tt.cursor)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("DecodeCursor(%q) = %d, want an error", tt.cursor, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeCursor(%q): %v", tt.cursor, err)
+			}
+			if got != tt.want {
+				t.Errorf("DecodeCursor(%q) = %d, want %d", tt.cursor, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"sales-analytics/internal/export"
+	"sales-analytics/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Supported payment method cross-tabs
+const (
+	CrossTabRegion = "region"
+	CrossTabMonth  = "month"
+)
+
+// IsValidCrossTab reports whether by is a supported payment method cross-tab
+func IsValidCrossTab(by string) bool {
+	return by == CrossTabRegion || by == CrossTabMonth
+}
+
+// GetRevenueByPaymentMethod returns a page of revenue, order count and average order value per
+// payment method
+func (s *RevenueService) GetRevenueByPaymentMethod(filter models.RevenueFilter, page models.PageRequest) (*models.Page[models.PaymentMethodRevenue], error) {
+	results, err := paginate[models.PaymentMethodRevenue](s.db, s.paymentMethodBreakdown(filter, page), breakdownColumns{key: "payment_method", name: "payment_method"}, page)
+	if err != nil {
+		return nil, fmt.Errorf("error querying revenue by payment method: %v", err)
+	}
+	results.RevenueDefinition = &filter.Revenue
+
+	return results, nil
+}
+
+// RevenueByPaymentMethodTable returns the requested page of revenue by payment method as an export
+// table streamed from the database
+func (s *RevenueService) RevenueByPaymentMethodTable(filter models.RevenueFilter, page models.PageRequest) (*export.Table, error) {
+	return pageTable[models.PaymentMethodRevenue](s.db, s.paymentMethodBreakdown(filter, page), breakdownColumns{key: "payment_method", name: "payment_method"}, page)
+}
+
+// paymentMethodBreakdown selects the revenue, quantity and orders of every payment method matching
+// the filter
+func (s *RevenueService) paymentMethodBreakdown(filter models.RevenueFilter, page models.PageRequest) func() *gorm.DB {
+	return func() *gorm.DB {
+		methods := s.db.Model(&models.Order{}).
+			Distinct("orders.payment_method")
+		if len(filter.PaymentMethods) > 0 {
+			methods = methods.Where("orders.payment_method IN ?", filter.PaymentMethods)
+		}
+
+		sales := filteredOrders(s.db, filter).
+			Select("orders.payment_method, SUM(" + revenueExpr(filter.Revenue) + ") as revenue, SUM(orders.quantity) as quantity, COUNT(*) as order_count").
+			Group("orders.payment_method")
+
+		query := s.db.Table("(?) methods", methods).
+			Select("methods.payment_method, COALESCE(sales.revenue, 0) as revenue, COALESCE(sales.quantity, 0) as quantity, "+
+				"COALESCE(sales.order_count, 0) as order_count, COALESCE(sales.revenue / sales.order_count, 0) as average_order_value").
+			Joins("LEFT JOIN (?) sales ON sales.payment_method = methods.payment_method", sales)
+		if !page.IncludeZero {
+			query = query.Where("sales.payment_method IS NOT NULL")
+		}
+		return query
+	}
+}
+
+// GetPaymentMethodCrossTab returns the revenue and orders of every payment method per region or
+// per month. Months without orders are zero-filled; payment methods are ordered by revenue.
+func (s *RevenueService) GetPaymentMethodCrossTab(filter models.RevenueFilter, by string) (*models.PaymentMethodCrossTab, error) {
+	var columnExpr string
+	switch by {
+	case CrossTabRegion:
+		columnExpr = "customers.region"
+	case CrossTabMonth:
+		columnExpr = "to_char(" + monthExpr + ", 'YYYY-MM')"
+	default:
+		return nil, fmt.Errorf("unsupported cross-tab '%s'", by)
+	}
+
+	var cells []struct {
+		PaymentMethod string
+		ColumnKey     string
+		Revenue       float64
+		OrderCount    int64
+	}
+
+	err := filteredOrders(s.db, filter).
+		Select("orders.payment_method, " + columnExpr + " as column_key, SUM(" + revenueExpr(filter.Revenue) + ") as revenue, COUNT(*) as order_count").
+		Group("1, 2").
+		Scan(&cells).Error
+	if err != nil {
+		return nil, fmt.Errorf("error querying payment method cross-tab: %v", err)
+	}
+
+	var columns []string
+	if by == CrossTabMonth {
+		for _, month := range periodBuckets(filter.StartDate, filter.EndDate, GranularityMonth) {
+			columns = append(columns, month.Format("2006-01"))
+		}
+	} else {
+		seen := make(map[string]bool)
+		for _, cell := range cells {
+			if !seen[cell.ColumnKey] {
+				seen[cell.ColumnKey] = true
+				columns = append(columns, cell.ColumnKey)
+			}
+		}
+		sort.Strings(columns)
+	}
+
+	type totals struct {
+		revenue float64
+		orders  int64
+	}
+	byMethod := make(map[string]map[string]totals)
+	methodTotals := make(map[string]totals)
+	columnOrders := make(map[string]int64)
+	for _, cell := range cells {
+		if byMethod[cell.PaymentMethod] == nil {
+			byMethod[cell.PaymentMethod] = make(map[string]totals)
+		}
+		byMethod[cell.PaymentMethod][cell.ColumnKey] = totals{revenue: cell.Revenue, orders: cell.OrderCount}
+
+		t := methodTotals[cell.PaymentMethod]
+		t.revenue += cell.Revenue
+		t.orders += cell.OrderCount
+		methodTotals[cell.PaymentMethod] = t
+		columnOrders[cell.ColumnKey] += cell.OrderCount
+	}
+
+	result := &models.PaymentMethodCrossTab{
+		By:                by,
+		RevenueDefinition: filter.Revenue,
+		Columns:           columns,
+		Rows:              make([]models.PaymentMethodCrossTabRow, 0, len(byMethod)),
+	}
+	if result.Columns == nil {
+		result.Columns = []string{}
+	}
+
+	for method, values := range byMethod {
+		row := models.PaymentMethodCrossTabRow{
+			PaymentMethod: method,
+			Revenue:       methodTotals[method].revenue,
+			OrderCount:    methodTotals[method].orders,
+			Cells:         make([]models.PaymentMethodCrossTabCell, 0, len(columns)),
+		}
+		for _, column := range columns {
+			cell := models.PaymentMethodCrossTabCell{
+				Column:     column,
+				Revenue:    values[column].revenue,
+				OrderCount: values[column].orders,
+			}
+			if columnOrders[column] > 0 {
+				cell.OrderShare = float64(cell.OrderCount) / float64(columnOrders[column])
+			}
+			row.Cells = append(row.Cells, cell)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	sort.Slice(result.Rows, func(i, j int) bool {
+		if result.Rows[i].Revenue != result.Rows[j].Revenue {
+			return result.Rows[i].Revenue > result.Rows[j].Revenue
+		}
+		return result.Rows[i].PaymentMethod < result.Rows[j].PaymentMethod
+	})
+
+	return result, nil
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"sales-analytics/internal/export"
+	"sales-analytics/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// MaxPivotDimensions is the largest number of dimensions a pivot query can group by
+const MaxPivotDimensions = 3
+
+// pivotDimension is a column a pivot query can group by. Dimensions identified by an ID also
+// return a human readable name in labelColumn.
+type pivotDimension struct {
+	column      string
+	label       string
+	labelColumn string
+}
+
+var pivotDimensions = map[string]pivotDimension{
+	"product":        {column: "products.product_id", label: "product_name", labelColumn: "products.name"},
+	"category":       {column: "products.category"},
+	"region":         {column: "customers.region"},
+	"payment_method": {column: "orders.payment_method"},
+	"month":          {column: "to_char(" + monthExpr + ", 'YYYY-MM')"},
+	"customer":       {column: "customers.customer_id", label: "customer_name", labelColumn: "customers.name"},
+}
+
+// pivotMeasures maps every measure to its aggregate under a revenue definition
+var pivotMeasures = map[string]func(models.RevenueDefinition) string{
+	"revenue":  func(def models.RevenueDefinition) string { return "SUM(" + revenueExpr(def) + ")" },
+	"quantity": func(models.RevenueDefinition) string { return "SUM(orders.quantity)" },
+	"orders":   func(models.RevenueDefinition) string { return "COUNT(*)" },
+	"discount": func(def models.RevenueDefinition) string { return "SUM(" + discountExpr(def) + ")" },
+	"avg_order_value": func(def models.RevenueDefinition) string {
+		return "SUM(" + revenueExpr(def) + ") / COUNT(*)"
+	},
+}
+
+// PivotDimensionNames lists the supported pivot dimensions in alphabetical order
+func PivotDimensionNames() []string {
+	return sortedKeys(pivotDimensions)
+}
+
+// PivotMeasureNames lists the supported pivot measures in alphabetical order
+func PivotMeasureNames() []string {
+	return sortedKeys(pivotMeasures)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// PivotOptions selects the dimensions and measures of a pivot query and the page to return.
+// Sort names one of the requested dimensions or measures.
+type PivotOptions struct {
+	Dimensions []string
+	Measures   []string
+	Sort       string
+	Descending bool
+	Limit      int
+	Offset     int
+}
+
+// Validate checks the options against the dimension and measure whitelists
+func (o PivotOptions) Validate() error {
+	if len(o.Dimensions) == 0 || len(o.Dimensions) > MaxPivotDimensions {
+		return fmt.Errorf("between 1 and %d dimensions are required", MaxPivotDimensions)
+	}
+	if len(o.Measures) == 0 {
+		return fmt.Errorf("at least one measure is required")
+	}
+
+	seen := make(map[string]bool, len(o.Dimensions)+len(o.Measures))
+	for _, dimension := range o.Dimensions {
+		if _, ok := pivotDimensions[dimension]; !ok {
+			return fmt.Errorf("unknown dimension '%s'", dimension)
+		}
+		if seen[dimension] {
+			return fmt.Errorf("dimension '%s' is repeated", dimension)
+		}
+		seen[dimension] = true
+	}
+	for _, measure := range o.Measures {
+		if _, ok := pivotMeasures[measure]; !ok {
+			return fmt.Errorf("unknown measure '%s'", measure)
+		}
+		if seen[measure] {
+			return fmt.Errorf("measure '%s' is repeated", measure)
+		}
+		seen[measure] = true
+	}
+
+	if o.Sort != "" && !seen[o.Sort] {
+		return fmt.Errorf("sort '%s' must be one of the requested dimensions or measures", o.Sort)
+	}
+	return nil
+}
+
+type PivotService struct {
+	db *gorm.DB
+}
+
+func NewPivotService(db *gorm.DB) *PivotService {
+	return &PivotService{db: db}
+}
+
+// PivotColumns lists the output columns of a pivot query in order: the dimensions, with the
+// name column following product and customer, then the measures
+func PivotColumns(opts PivotOptions) (dimensions []string, measures []string) {
+	for _, name := range opts.Dimensions {
+		dimensions = append(dimensions, name)
+		if label := pivotDimensions[name].label; label != "" {
+			dimensions = append(dimensions, label)
+		}
+	}
+	return dimensions, opts.Measures
+}
+
+// pivotQuery returns a builder for the grouped query. Column names come from the whitelists
+// above, so they are safe to inline into the query.
+func (s *PivotService) pivotQuery(filter models.RevenueFilter, opts PivotOptions) func() *gorm.DB {
+	var selects, groupBy []string
+	for _, name := range opts.Dimensions {
+		dimension := pivotDimensions[name]
+		selects = append(selects, fmt.Sprintf("%s as %q", dimension.column, name))
+		if dimension.labelColumn != "" {
+			selects = append(selects, fmt.Sprintf("%s as %q", dimension.labelColumn, dimension.label))
+		}
+	}
+	for i := range selects {
+		groupBy = append(groupBy, fmt.Sprint(i+1))
+	}
+	for _, name := range opts.Measures {
+		selects = append(selects, fmt.Sprintf("CAST(%s AS double precision) as %q", pivotMeasures[name](filter.Revenue), name))
+	}
+
+	return func() *gorm.DB {
+		return filteredOrders(s.db, filter).
+			Select(strings.Join(selects, ", ")).
+			Group(strings.Join(groupBy, ", "))
+	}
+}
+
+// Query groups the filtered orders by the requested dimensions and returns a page of the
+// requested measures. Only combinations with orders are returned.
+func (s *PivotService) Query(filter models.RevenueFilter, opts PivotOptions) (*models.PivotResult, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	var total int64
+	if err := s.db.Table("(?) pivot", s.pivotQuery(filter, opts)()).Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("error counting pivot rows: %v", err)
+	}
+
+	data := []models.PivotRow{}
+	err := s.Stream(filter, opts, func(row models.PivotRow) error {
+		data = append(data, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.PivotResult{
+		Dimensions: opts.Dimensions,
+		Measures:   opts.Measures,
+		Page: models.Page[models.PivotRow]{
+			Data:              data,
+			Total:             total,
+			Limit:             opts.Limit,
+			Offset:            opts.Offset,
+			RevenueDefinition: &filter.Revenue,
+		},
+	}
+	if next := opts.Offset + len(data); opts.Limit > 0 && int64(next) < total {
+		result.NextCursor = EncodeCursor(next)
+	}
+
+	return result, nil
+}
+
+// Stream runs a pivot query and calls fn for every row as it is read from the database, so
+// large results never have to be held in memory. Stream stops at the first error from fn.
+func (s *PivotService) Stream(filter models.RevenueFilter, opts PivotOptions, fn func(models.PivotRow) error) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	sortColumn := opts.Sort
+	if sortColumn == "" {
+		sortColumn = opts.Measures[0]
+	}
+	direction := "ASC"
+	if opts.Descending {
+		direction = "DESC"
+	}
+	orderBy := []string{fmt.Sprintf("%q %s", sortColumn, direction)}
+	for _, name := range opts.Dimensions {
+		if name != sortColumn {
+			orderBy = append(orderBy, fmt.Sprintf("%q ASC", name))
+		}
+	}
+
+	query := s.pivotQuery(filter, opts)().Order(strings.Join(orderBy, ", "))
+	if opts.Limit > 0 {
+		query = query.Limit(opts.Limit)
+	}
+	if opts.Offset > 0 {
+		query = query.Offset(opts.Offset)
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		return fmt.Errorf("error querying pivot: %v", err)
+	}
+	defer rows.Close()
+
+	dimColumns, measureColumns := PivotColumns(opts)
+	dims := make([]sql.NullString, len(dimColumns))
+	measures := make([]sql.NullFloat64, len(measureColumns))
+	dest := make([]interface{}, 0, len(dims)+len(measures))
+	for i := range dims {
+		dest = append(dest, &dims[i])
+	}
+	for i := range measures {
+		dest = append(dest, &measures[i])
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return fmt.Errorf("error reading pivot row: %v", err)
+		}
+
+		row := make(models.PivotRow, len(dest))
+		for i, name := range dimColumns {
+			row[name] = dims[i].String
+		}
+		for i, name := range measureColumns {
+			row[name] = measures[i].Float64
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading pivot rows: %v", err)
+	}
+
+	return nil
+}
+
+// Table returns the pivot query as an export table whose rows are streamed from the database
+func (s *PivotService) Table(filter models.RevenueFilter, opts PivotOptions) *export.Table {
+	dimensions, measures := PivotColumns(opts)
+	columns := make([]export.Column, 0, len(dimensions)+len(measures))
+	for _, name := range dimensions {
+		columns = append(columns, export.Column{Name: name, Type: export.TypeString})
+	}
+	for _, name := range measures {
+		columns = append(columns, export.Column{Name: name, Type: export.TypeFloat})
+	}
+
+	return export.NewTable(columns, func(yield func([]interface{}) error) error {
+		return s.Stream(filter, opts, func(row models.PivotRow) error {
+			values := make([]interface{}, len(columns))
+			for i, column := range columns {
+				values[i] = row[column.Name]
+			}
+			return yield(values)
+		})
+	})
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"sales-analytics/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// priceCandidates is the temporary table a direct load collects a batch's product prices in
+const priceCandidates = "price_candidates"
+
+// dailyPrices collects the price of each product per day of sale, keyed by product and day
+type dailyPrices map[string]models.ProductPrice
+
+// add records the unit price of an order, replacing an earlier price of the product on that day
+func (p dailyPrices) add(order models.Order) {
+	day := order.DateOfSale.UTC()
+	p[order.ProductID+"|"+day.Format("2006-01-02")] = models.ProductPrice{
+		ProductID:     order.ProductID,
+		UnitPrice:     order.UnitPrice,
+		EffectiveDate: time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// recordPrices adds the product prices of a batch to the price history. It must run inside a
+// transaction.
+func recordPrices(tx *gorm.DB, prices []models.ProductPrice) error {
+	if len(prices) == 0 {
+		return nil
+	}
+
+	// The temporary table lives on the transaction's connection and is dropped on commit
+	if err := tx.Exec(fmt.Sprintf(
+		"CREATE TEMPORARY TABLE %s (product_id varchar(50), unit_price decimal(10,2), effective_date date) ON COMMIT DROP",
+		priceCandidates,
+	)).Error; err != nil {
+		return fmt.Errorf("error creating price candidates table: %v", err)
+	}
+
+	rows := make([]map[string]interface{}, len(prices))
+	for i, price := range prices {
+		rows[i] = map[string]interface{}{
+			"product_id":     price.ProductID,
+			"unit_price":     price.UnitPrice,
+			"effective_date": price.EffectiveDate,
+		}
+	}
+	if err := tx.Table(priceCandidates).Create(&rows).Error; err != nil {
+		return fmt.Errorf("error recording price candidates: %v", err)
+	}
+
+	return mergePriceChanges(tx, priceCandidates)
+}
+
+// mergePriceChanges merges the product prices in source, one per product and day, into the price
+// history so it only holds the days a product's price changed. A price is added when it differs
+// from the one before it, counting both the history and the other new prices, and replaces an
+// entry on the same day. As prices can arrive out of date order, entries that end up repeating
+// the price before them are then removed.
+func mergePriceChanges(tx *gorm.DB, source string) error {
+	if err := tx.Exec(fmt.Sprintf(`
+		WITH candidates AS (
+			SELECT product_id, unit_price, effective_date FROM %[1]s
+		), timeline AS (
+			SELECT product_id, unit_price, effective_date, TRUE AS candidate FROM candidates
+			UNION ALL
+			SELECT product_id, unit_price, effective_date, FALSE FROM product_prices
+			WHERE product_id IN (SELECT product_id FROM candidates)
+				AND NOT EXISTS (
+					SELECT 1 FROM candidates
+					WHERE candidates.product_id = product_prices.product_id
+						AND candidates.effective_date = product_prices.effective_date
+				)
+		), changes AS (
+			SELECT product_id, unit_price, effective_date, candidate,
+				LAG(unit_price) OVER (PARTITION BY product_id ORDER BY effective_date) AS previous
+			FROM timeline
+		)
+		INSERT INTO product_prices (product_id, unit_price, effective_date, created_at, updated_at)
+		SELECT product_id, unit_price, effective_date, NOW(), NOW() FROM changes
+		WHERE candidate AND (
+			previous IS DISTINCT FROM unit_price OR EXISTS (
+				SELECT 1 FROM product_prices
+				WHERE product_prices.product_id = changes.product_id
+					AND product_prices.effective_date = changes.effective_date
+			)
+		)
+		ON CONFLICT (product_id, effective_date) DO UPDATE
+		SET unit_price = excluded.unit_price, updated_at = excluded.updated_at
+	`, source)).Error; err != nil {
+		return fmt.Errorf("error recording price changes: %v", err)
+	}
+
+	return compactPriceHistory(tx, fmt.Sprintf("SELECT product_id FROM %s", source))
+}
+
+// CompactPriceHistory removes the price history entries of every product that repeat the price
+// before them
+func CompactPriceHistory(db *gorm.DB) error {
+	return compactPriceHistory(db, "")
+}
+
+// compactPriceHistory removes the price history entries that repeat the price before them for
+// the products selected by products, or for every product when it is empty
+func compactPriceHistory(tx *gorm.DB, products string) error {
+	where := ""
+	if products != "" {
+		where = fmt.Sprintf("WHERE product_id IN (%s)", products)
+	}
+	if err := tx.Exec(fmt.Sprintf(`
+		DELETE FROM product_prices WHERE id IN (
+			SELECT id FROM (
+				SELECT id, unit_price,
+					LAG(unit_price) OVER (PARTITION BY product_id ORDER BY effective_date) AS previous
+				FROM product_prices %s
+			) history
+			WHERE unit_price = previous
+		)
+	`, where)).Error; err != nil {
+		return fmt.Errorf("error compacting price history: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"sales-analytics/internal/models"
+)
+
+func TestDailyPrices(t *testing.T) {
+	day := func(value string) time.Time {
+		parsed, err := time.Parse("2006-01-02 15:04", value)
+		if err != nil {
+			t.Fatalf("parsing %s: %v", value, err)
+		}
+		return parsed
+	}
+	order := func(product, date string, price float64) models.Order {
+		return models.Order{ProductID: product, DateOfSale: day(date), UnitPrice: price}
+	}
+
+	tests := []struct {
+		name   string
+		orders []models.Order
+		want   []string
+	}{
+		{
+			name:   "one order",
+			orders: []models.Order{order("P1", "2024-01-01 00:00", 10)},
+			want:   []string{"P1 2024-01-01 10"},
+		},
+		{
+			name:   "last price of the day wins",
+			orders: []models.Order{order("P1", "2024-01-01 00:00", 10), order("P1", "2024-01-01 00:00", 12)},
+			want:   []string{"P1 2024-01-01 12"},
+		},
+		{
+			name:   "time of day ignored",
+			orders: []models.Order{order("P1", "2024-01-01 09:30", 10), order("P1", "2024-01-01 23:59", 11)},
+			want:   []string{"P1 2024-01-01 11"},
+		},
+		{
+			name: "days and products kept apart",
+			orders: []models.Order{
+				order("P1", "2024-01-01 00:00", 10), order("P1", "2024-01-02 00:00", 10),
+				order("P2", "2024-01-01 00:00", 5),
+			},
+			want: []string{"P1 2024-01-01 10", "P1 2024-01-02 10", "P2 2024-01-01 5"},
+		},
+		{
+			name: "day taken in UTC",
+			orders: []models.Order{
+				{ProductID: "P1", UnitPrice: 10, DateOfSale: time.Date(2024, 1, 2, 1, 0, 0, 0, time.FixedZone("UTC+3", 3*3600))},
+			},
+			want: []string{"P1 2024-01-01 10"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prices := make(dailyPrices)
+			for _, o := range tt.orders {
+				prices.add(o)
+			}
+
+			var got []string
+			for _, price := range mapToSlice(prices) {
+				if !price.EffectiveDate.Equal(price.EffectiveDate.Truncate(24 * time.Hour)) {
+					t.Errorf("effective date %v is not the start of a day", price.EffectiveDate)
+				}
+				got = append(got, price.ProductID+" "+price.EffectiveDate.Format("2006-01-02")+" "+
+					strconv.FormatFloat(price.UnitPrice, 'f', -1, 64))
+			}
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("prices = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"sales-analytics/internal/cache"
+	"sales-analytics/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Cache statuses of a query result
+const (
+	CacheHit    = "HIT"
+	CacheMiss   = "MISS"
+	CacheBypass = "BYPASS"
+)
+
+// QueryCache caches query results in a backend, keyed by the query and the data version they
+// were computed against. A nil backend disables caching; results encoding to more than
+// maxEntryBytes are never cached.
+type QueryCache struct {
+	backend       cache.Backend
+	maxEntryBytes int64
+	logger        *logrus.Logger
+
+	mu      sync.RWMutex
+	version models.DataVersion
+}
+
+func NewQueryCache(backend cache.Backend, maxEntryBytes int64, version models.DataVersion, logger *logrus.Logger) *QueryCache {
+	return &QueryCache{
+		backend:       backend,
+		maxEntryBytes: maxEntryBytes,
+		logger:        logger,
+		version:       version,
+	}
+}
+
+// Version returns the version of the data results are currently cached for
+func (c *QueryCache) Version() models.DataVersion {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.version
+}
+
+// Invalidate drops every cached result and records the version of the newly loaded data
+func (c *QueryCache) Invalidate(version models.DataVersion) {
+	c.mu.Lock()
+	c.version = version
+	c.mu.Unlock()
+
+	if c.backend != nil {
+		c.backend.Purge()
+	}
+}
+
+// normalizedFilter is a revenue filter with its lists sorted and deduplicated, so equivalent
+// queries share a cache key
+type normalizedFilter struct {
+	StartDate      string
+	EndDate        string
+	Categories     []string
+	Regions        []string
+	ProductIDs     []string
+	CustomerIDs    []string
+	PaymentMethods []string
+	MinQuantity    *int
+	MaxQuantity    *int
+	Revenue        models.RevenueDefinition
+}
+
+// normalizeList returns a sorted copy of values without duplicates
+func normalizeList(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// QueryKey builds the cache key of a query from the endpoint, its filter and any other
+// parameters affecting the result
+func QueryKey(endpoint string, filter models.RevenueFilter, params ...interface{}) string {
+	key := struct {
+		Filter normalizedFilter
+		Params []interface{}
+	}{
+		Filter: normalizedFilter{
+			StartDate:      filter.StartDate.Format("2006-01-02"),
+			EndDate:        filter.EndDate.Format("2006-01-02"),
+			Categories:     normalizeList(filter.Categories),
+			Regions:        normalizeList(filter.Regions),
+			ProductIDs:     normalizeList(filter.ProductIDs),
+			CustomerIDs:    normalizeList(filter.CustomerIDs),
+			PaymentMethods: normalizeList(filter.PaymentMethods),
+			MinQuantity:    filter.MinQuantity,
+			MaxQuantity:    filter.MaxQuantity,
+			Revenue:        filter.Revenue,
+		},
+		Params: params,
+	}
+
+	// Filters and parameters are plain values, so encoding cannot fail
+	data, _ := json.Marshal(key)
+	sum := sha256.Sum256(data)
+	return endpoint + ":" + hex.EncodeToString(sum[:])
+}
+
+// Cached returns the cached result of a query, or runs the query and caches its result. It also
+// returns the cache status and the data version the result belongs to.
+func Cached[T any](c *QueryCache, key string, query func() (T, error)) (T, string, models.DataVersion, error) {
+	version := c.Version()
+	if c.backend == nil {
+		result, err := query()
+		return result, CacheBypass, version, err
+	}
+
+	// Results are stored per data version, so a query that was running while a refresh finished
+	// never serves its result as the new version's
+	key = fmt.Sprintf("%d:%s", version.Version, key)
+
+	var result T
+	if data, ok := c.backend.Get(key); ok {
+		if err := json.Unmarshal(data, &result); err == nil {
+			return result, CacheHit, version, nil
+		}
+		c.logger.WithField("key", key).Warn("Discarding undecodable cached query result")
+	}
+
+	result, err := query()
+	if err != nil {
+		return result, CacheMiss, version, err
+	}
+
+	if data, err := json.Marshal(result); err != nil {
+		c.logger.WithError(err).WithField("key", key).Warn("Failed to encode query result for caching")
+	} else if int64(len(data)) <= c.maxEntryBytes {
+		c.backend.Set(key, data)
+	}
+
+	return result, CacheMiss, version, nil
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"sales-analytics/internal/cache"
+	"sales-analytics/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestCached(t *testing.T) {
+	tests := []struct {
+		name          string
+		backend       bool
+		result        string
+		maxEntryBytes int64
+		want          []string
+	}{
+		{name: "cached after the first query", backend: true, result: "small", maxEntryBytes: 100, want: []string{CacheMiss, CacheHit}},
+		{name: "oversized result not cached", backend: true, result: strings.Repeat("x", 200), maxEntryBytes: 100, want: []string{CacheMiss, CacheMiss}},
+		{name: "no backend", result: "small", maxEntryBytes: 100, want: []string{CacheBypass, CacheBypass}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var backend cache.Backend
+			if tt.backend {
+				backend = cache.NewLRU(10, 1<<20)
+			}
+			queryCache := NewQueryCache(backend, tt.maxEntryBytes, models.DataVersion{Version: 1}, logrus.New())
+
+			runs := 0
+			for i, want := range tt.want {
+				result, status, _, err := Cached(queryCache, "key", func() (string, error) {
+					runs++
+					return tt.result, nil
+				})
+				if err != nil {
+					t.Fatalf("Cached: %v", err)
+				}
+				if result != tt.result || status != want {
+					t.Errorf("call %d = %.10q %s, want %.10q %s", i+1, result, status, tt.result, want)
+				}
+			}
+
+			misses := 0
+			for _, status := range tt.want {
+				if status != CacheHit {
+					misses++
+				}
+			}
+			if runs != misses {
+				t.Errorf("query ran %d times, want %d", runs, misses)
+			}
+		})
+	}
+}
+
+func TestCachedInvalidate(t *testing.T) {
+	queryCache := NewQueryCache(cache.NewLRU(10, 1<<20), 100, models.DataVersion{Version: 1}, logrus.New())
+	query := func() (int, error) { return 1, nil }
+
+	Cached(queryCache, "key", query)
+	queryCache.Invalidate(models.DataVersion{Version: 2})
+
+	_, status, version, _ := Cached(queryCache, "key", query)
+	if status != CacheMiss || version.Version != 2 {
+		t.Errorf("after Invalidate = %s at version %d, want %s at version 2", status, version.Version, CacheMiss)
+	}
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"sales-analytics/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// reconciledOrderColumns are the order fields a reconciling load overwrites when the source
+// file changes them
+var reconciledOrderColumns = []string{
+	"customer_id", "product_id", "date_of_sale", "quantity", "unit_price", "discount", "shipping_cost", "payment_method",
+}
+
+// orderUpsert updates existing orders only when one of their fields changed or they were
+// soft-deleted, so RowsAffected counts the inserted and the actually updated orders
+func orderUpsert() clause.OnConflict {
+	changed := make([]string, 0, len(reconciledOrderColumns)+1)
+	for _, column := range reconciledOrderColumns {
+		changed = append(changed, fmt.Sprintf("orders.%s IS DISTINCT FROM excluded.%s", column, column))
+	}
+	// An order that reappears in the source is restored
+	changed = append(changed, "orders.deleted_at IS NOT NULL")
+
+	assigned := append(append([]string{}, reconciledOrderColumns...), "updated_at", "deleted_at")
+	return clause.OnConflict{
+		Columns:   []clause.Column{{Name: "order_id"}},
+		DoUpdates: clause.AssignmentColumns(assigned),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: strings.Join(changed, " OR ")},
+		}},
+	}
+}
+
+// uniqueOrders drops all but the last row of each order ID, as Postgres cannot update the same
+// row twice in one upsert
+func uniqueOrders(orders []models.Order) []models.Order {
+	positions := make(map[string]int, len(orders))
+	unique := make([]models.Order, 0, len(orders))
+	for _, order := range orders {
+		if i, ok := positions[order.OrderID]; ok {
+			unique[i] = order
+			continue
+		}
+		positions[order.OrderID] = len(unique)
+		unique = append(unique, order)
+	}
+	return unique
+}
+
+// reconcileOrders upserts a batch of orders and returns how many were inserted and updated.
+// Orders that did not change are left untouched.
+func reconcileOrders(tx *gorm.DB, orders []models.Order, batchSize int) (inserted, updated int64, err error) {
+	ids := make([]string, len(orders))
+	for i, order := range orders {
+		ids[i] = order.OrderID
+	}
+
+	var existing int64
+	if err := tx.Unscoped().Model(&models.Order{}).Where("order_id IN ?", ids).Count(&existing).Error; err != nil {
+		return 0, 0, fmt.Errorf("error counting existing orders: %v", err)
+	}
+
+	res := tx.Clauses(orderUpsert()).CreateInBatches(orders, batchSize)
+	if res.Error != nil {
+		return 0, 0, fmt.Errorf("error upserting orders: %v", res.Error)
+	}
+
+	inserted, updated = reconcileCounts(int64(len(orders)), existing, res.RowsAffected)
+	return inserted, updated, nil
+}
+
+// reconcileCounts splits the rows affected by an order upsert into inserted and updated orders.
+// Every order that did not exist is inserted, so the remaining affected rows are updates.
+func reconcileCounts(orders, existing, affected int64) (inserted, updated int64) {
+	inserted = orders - existing
+	return inserted, affected - inserted
+}
+
+// seenOrders collects the order IDs of a full snapshot so the orders missing from it can be
+// soft-deleted once the whole file has been loaded
+type seenOrders struct {
+	ids map[string]struct{}
+	// incomplete is set when a rejected row's order ID could not be read, in which case
+	// nothing is deleted
+	incomplete bool
+}
+
+func newSeenOrders() *seenOrders {
+	return &seenOrders{ids: make(map[string]struct{})}
+}
+
+func (s *seenOrders) add(orderID string) {
+	s.ids[orderID] = struct{}{}
+}
+
+// addRejected marks the order of a rejected record as seen, so a bad row does not delete the
+// order it describes
+func (s *seenOrders) addRejected(record []string, cols columnIndex) {
+	if len(record) != cols.width {
+		s.incomplete = true
+		return
+	}
+	orderID := cols.value(record, FieldOrderID)
+	if orderID == "" {
+		s.incomplete = true
+		return
+	}
+	s.add(orderID)
+}
+
+// deleteMissingOrders soft-deletes the orders whose IDs were not seen in a full snapshot and
+// returns how many were deleted. It must run inside a transaction.
+func (s *LoaderService) deleteMissingOrders(tx *gorm.DB, seen *seenOrders) (int, error) {
+	if seen.incomplete {
+		return 0, fmt.Errorf("not deleting missing orders: some rejected rows have no readable order ID")
+	}
+	if len(seen.ids) == 0 {
+		return 0, fmt.Errorf("not deleting missing orders: the CSV file contains no orders")
+	}
+
+	// The temporary table lives on the transaction's connection and is dropped on commit
+	if err := tx.Exec("CREATE TEMPORARY TABLE seen_orders (order_id varchar(50) PRIMARY KEY) ON COMMIT DROP").Error; err != nil {
+		return 0, fmt.Errorf("error creating seen orders table: %v", err)
+	}
+
+	rows := make([]map[string]interface{}, 0, s.batchSize)
+	insert := func() error {
+		if len(rows) == 0 {
+			return nil
+		}
+		if err := tx.Table("seen_orders").Create(&rows).Error; err != nil {
+			return fmt.Errorf("error recording seen orders: %v", err)
+		}
+		rows = rows[:0]
+		return nil
+	}
+	for id := range seen.ids {
+		rows = append(rows, map[string]interface{}{"order_id": id})
+		if len(rows) >= s.batchSize {
+			if err := insert(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := insert(); err != nil {
+		return 0, err
+	}
+
+	res := tx.Where("NOT EXISTS (SELECT 1 FROM seen_orders WHERE seen_orders.order_id = orders.order_id)").
+		Delete(&models.Order{})
+	if res.Error != nil {
+		return 0, fmt.Errorf("error deleting missing orders: %v", res.Error)
+	}
+	return int(res.RowsAffected), nil
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"sales-analytics/internal/models"
+)
+
+func TestReconcileCounts(t *testing.T) {
+	tests := []struct {
+		name                       string
+		orders, existing, affected int64
+		inserted, updated          int64
+	}{
+		{name: "all new", orders: 5, existing: 0, affected: 5, inserted: 5, updated: 0},
+		{name: "all unchanged", orders: 5, existing: 5, affected: 0, inserted: 0, updated: 0},
+		{name: "some changed", orders: 5, existing: 5, affected: 2, inserted: 0, updated: 2},
+		{name: "new and changed", orders: 5, existing: 3, affected: 3, inserted: 2, updated: 1},
+		{name: "restored", orders: 1, existing: 1, affected: 1, inserted: 0, updated: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inserted, updated := reconcileCounts(tt.orders, tt.existing, tt.affected)
+			if inserted != tt.inserted || updated != tt.updated {
+				t.Errorf("reconcileCounts = %d inserted, %d updated, want %d, %d", inserted, updated, tt.inserted, tt.updated)
+			}
+		})
+	}
+}
+
+func TestUniqueOrders(t *testing.T) {
+	orders := []models.Order{
+		{OrderID: "1", Quantity: 1},
+		{OrderID: "2", Quantity: 1},
+		{OrderID: "1", Quantity: 2},
+		{OrderID: "3", Quantity: 1},
+		{OrderID: "1", Quantity: 3},
+	}
+
+	got := uniqueOrders(orders)
+	want := []models.Order{
+		{OrderID: "1", Quantity: 3},
+		{OrderID: "2", Quantity: 1},
+		{OrderID: "3", Quantity: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("uniqueOrders = %+v, want %+v", got, want)
+	}
+}
+
+func TestSeenOrdersAddRejected(t *testing.T) {
+	cols := columnIndex{positions: map[string]int{FieldOrderID: 0, FieldProductID: 1}, width: 2}
+
+	tests := []struct {
+		name       string
+		record     []string
+		seen       []string
+		incomplete bool
+	}{
+		{name: "readable order ID", record: []string{" 1001 ", "bad"}, seen: []string{"1001"}},
+		{name: "empty order ID", record: []string{"", "P1"}, incomplete: true},
+		{name: "wrong column count", record: []string{"1001"}, incomplete: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := newSeenOrders()
+			seen.addRejected(tt.record, cols)
+
+			var ids []string
+			for id := range seen.ids {
+				ids = append(ids, id)
+			}
+			if !reflect.DeepEqual(ids, tt.seen) || seen.incomplete != tt.incomplete {
+				t.Errorf("seen %q incomplete %v, want %q incomplete %v", ids, seen.incomplete, tt.seen, tt.incomplete)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+
+	"sales-analytics/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// RejectStore persists CSV rows that failed validation so they can be reviewed per refresh job
+type RejectStore struct {
+	db        *gorm.DB
+	batchSize int
+}
+
+func NewRejectStore(db *gorm.DB, batchSize int) *RejectStore {
+	return &RejectStore{
+		db:        db,
+		batchSize: batchSize,
+	}
+}
+
+// Save stores a batch of rejected rows
+func (s *RejectStore) Save(rows []models.RejectedRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := s.db.CreateInBatches(rows, s.batchSize).Error; err != nil {
+		return fmt.Errorf("error saving rejected rows: %v", err)
+	}
+	return nil
+}
+
+// ForEach calls fn for every rejected row of a job in line order, reading them in batches paged
+// by line number with the ID as a tie-breaker
+func (s *RejectStore) ForEach(jobID uint, fn func(models.RejectedRow) error) error {
+	var lastLine int
+	var lastID uint
+
+	for {
+		var batch []models.RejectedRow
+		query := s.db.Where("job_id = ?", jobID)
+		if lastID != 0 {
+			query = query.Where("(line_number, id) > (?, ?)", lastLine, lastID)
+		}
+		if err := query.Order("line_number, id").Limit(s.batchSize).Find(&batch).Error; err != nil {
+			return fmt.Errorf("error reading rejected rows: %v", err)
+		}
+
+		for _, row := range batch {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		if len(batch) < s.batchSize {
+			return nil
+		}
+
+		last := batch[len(batch)-1]
+		lastLine, lastID = last.LineNumber, last.ID
+	}
+}
+
+// encodeRecord turns a parsed CSV record back into a single CSV line
+func encodeRecord(record []string) string {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write(record)
+	w.Flush()
+	return string(bytes.TrimRight(buf.Bytes(), "\r\n"))
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"sales-analytics/internal/export"
+	"sales-analytics/internal/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// reportPollInterval is how often idle workers look for queued jobs they were not woken for
+const reportPollInterval = 30 * time.Second
+
+// ReportService generates report files in the background. Jobs are queued in the database and
+// claimed by a fixed pool of workers, so queued jobs survive restarts.
+type ReportService struct {
+	db       *gorm.DB
+	pivot    *PivotService
+	logger   *logrus.Logger
+	dir      string
+	ttl      time.Duration
+	workers  int
+	defaults models.RevenueDefinition
+
+	wake chan struct{}
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewReportService(db *gorm.DB, pivot *PivotService, logger *logrus.Logger, dir string, ttl time.Duration, workers int, defaults models.RevenueDefinition) *ReportService {
+	return &ReportService{
+		db:       db,
+		pivot:    pivot,
+		logger:   logger,
+		dir:      dir,
+		ttl:      ttl,
+		workers:  workers,
+		defaults: defaults,
+		wake:     make(chan struct{}, workers),
+		stop:     make(chan struct{}),
+	}
+}
+
+// ResolveReportSpec validates a report spec and turns it into the filter and pivot options it
+// runs with
+func ResolveReportSpec(spec models.ReportSpec, defaults models.RevenueDefinition) (models.RevenueFilter, PivotOptions, error) {
+	startDate, err := time.Parse("2006-01-02", spec.StartDate)
+	if err != nil {
+		return models.RevenueFilter{}, PivotOptions{}, fmt.Errorf("invalid start_date '%s', expected YYYY-MM-DD", spec.StartDate)
+	}
+	endDate, err := time.Parse("2006-01-02", spec.EndDate)
+	if err != nil {
+		return models.RevenueFilter{}, PivotOptions{}, fmt.Errorf("invalid end_date '%s', expected YYYY-MM-DD", spec.EndDate)
+	}
+	if endDate.Before(startDate) {
+		return models.RevenueFilter{}, PivotOptions{}, fmt.Errorf("end_date cannot be before start_date")
+	}
+	if spec.MinQuantity != nil && spec.MaxQuantity != nil && *spec.MaxQuantity < *spec.MinQuantity {
+		return models.RevenueFilter{}, PivotOptions{}, fmt.Errorf("max_quantity cannot be less than min_quantity")
+	}
+
+	revenue := defaults
+	if spec.RevenueDefinition != nil {
+		revenue, err = NewRevenueDefinition(spec.RevenueDefinition.Basis, spec.RevenueDefinition.DiscountType, spec.RevenueDefinition.IncludeShipping)
+		if err != nil {
+			return models.RevenueFilter{}, PivotOptions{}, err
+		}
+	}
+
+	filter := models.RevenueFilter{
+		StartDate:      startDate,
+		EndDate:        endDate,
+		Categories:     spec.Categories,
+		Regions:        spec.Regions,
+		ProductIDs:     spec.ProductIDs,
+		CustomerIDs:    spec.CustomerIDs,
+		PaymentMethods: spec.PaymentMethods,
+		MinQuantity:    spec.MinQuantity,
+		MaxQuantity:    spec.MaxQuantity,
+		Revenue:        revenue,
+	}
+
+	opts := PivotOptions{
+		Dimensions: spec.Dimensions,
+		Measures:   spec.Measures,
+		Sort:       spec.Sort,
+		Descending: true,
+	}
+	switch spec.Order {
+	case "", "desc":
+	case "asc":
+		opts.Descending = false
+	default:
+		return models.RevenueFilter{}, PivotOptions{}, fmt.Errorf("invalid order '%s', expected asc or desc", spec.Order)
+	}
+	if err := opts.Validate(); err != nil {
+		return models.RevenueFilter{}, PivotOptions{}, err
+	}
+
+	return filter, opts, nil
+}
+
+// ValidateSpec checks that a report spec can be run, defaulting the format to csv
+func (s *ReportService) ValidateSpec(spec *models.ReportSpec) error {
+	if spec.Format == "" {
+		spec.Format = export.FormatCSV
+	}
+	if spec.Format == export.FormatJSON || !export.IsValidFormat(spec.Format) {
+		return fmt.Errorf("invalid format '%s', expected csv, xlsx or parquet", spec.Format)
+	}
+	_, _, err := ResolveReportSpec(*spec, s.defaults)
+	return err
+}
+
+// CreateReport queues a job to generate a validated report spec
+func (s *ReportService) CreateReport(spec models.ReportSpec) (*models.ReportJob, error) {
+	job := &models.ReportJob{
+		Status: models.ReportStatusQueued,
+		Spec:   spec,
+	}
+	if err := s.db.Create(job).Error; err != nil {
+		return nil, fmt.Errorf("error creating report job: %v", err)
+	}
+
+	// Wake an idle worker if there is one; otherwise the job is picked up by the next free one
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return job, nil
+}
+
+// GetReport returns a single report job
+func (s *ReportService) GetReport(id uint) (*models.ReportJob, error) {
+	var job models.ReportJob
+	if err := s.db.First(&job, id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Start requeues jobs interrupted by a previous shutdown and starts the workers
+func (s *ReportService) Start() {
+	err := s.db.Model(&models.ReportJob{}).
+		Where("status = ?", models.ReportStatusRunning).
+		Updates(map[string]interface{}{"status": models.ReportStatusQueued, "started_at": nil}).Error
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to requeue interrupted report jobs")
+	}
+
+	for i := 0; i < s.workers; i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+}
+
+// Stop waits for running reports to finish and stops the workers
+func (s *ReportService) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// work runs queued jobs one at a time until the service is stopped
+func (s *ReportService) work() {
+	defer s.wg.Done()
+
+	for {
+		job, err := s.claimNext()
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to claim report job")
+		}
+		if job != nil {
+			s.run(job)
+			continue
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-s.wake:
+		case <-time.After(reportPollInterval):
+		}
+	}
+}
+
+// claimNext marks the oldest queued job as running and returns it, or nil if none is queued.
+// SKIP LOCKED lets several workers claim jobs concurrently without taking the same one.
+func (s *ReportService) claimNext() (*models.ReportJob, error) {
+	var job models.ReportJob
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ?", models.ReportStatusQueued).
+			Order("id").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		job.Status = models.ReportStatusRunning
+		job.StartedAt = &now
+		return tx.Save(&job).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// run generates the report file of a claimed job and records the outcome
+func (s *ReportService) run(job *models.ReportJob) {
+	logger := s.logger.WithField("report_id", job.ID)
+	logger.Info("Generating report")
+
+	path, rows, err := s.generate(job)
+
+	now := time.Now()
+	job.FinishedAt = &now
+	if err != nil {
+		logger.WithError(err).Error("Report generation failed")
+		job.Status = models.ReportStatusFailed
+		job.Error = err.Error()
+	} else {
+		expiresAt := now.Add(s.ttl)
+		job.Status = models.ReportStatusCompleted
+		job.FilePath = path
+		job.RowCount = rows
+		job.ExpiresAt = &expiresAt
+		if info, statErr := os.Stat(path); statErr == nil {
+			job.FileSize = info.Size()
+		}
+		logger.WithField("rows", rows).Info("Report generated")
+	}
+
+	if err := s.db.Save(job).Error; err != nil {
+		logger.WithError(err).Error("Failed to save report job")
+	}
+}
+
+// generate writes the file of a report job
+func (s *ReportService) generate(job *models.ReportJob) (string, int64, error) {
+	return s.WriteFile(job.Spec, s.dir, fmt.Sprintf("report-%d", job.ID))
+}
+
+// WriteFile writes the report described by spec to dir/name.<format> and returns its path and
+// row count. The report goes to a temporary file that is moved into place once complete, so a
+// partially written file is never served.
+func (s *ReportService) WriteFile(spec models.ReportSpec, dir, name string) (string, int64, error) {
+	filter, opts, err := ResolveReportSpec(spec, s.defaults)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", 0, fmt.Errorf("error creating report directory: %v", err)
+	}
+
+	path := filepath.Join(dir, name+"."+spec.Format)
+	file, err := os.CreateTemp(dir, name+"-*.tmp")
+	if err != nil {
+		return "", 0, fmt.Errorf("error creating report file: %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	var rows int64
+	source := s.pivot.Table(filter, opts)
+	table := export.NewTable(source.Columns, func(yield func([]interface{}) error) error {
+		return source.EachRow(func(values []interface{}) error {
+			rows++
+			return yield(values)
+		})
+	})
+
+	if err := export.Write(file, spec.Format, table); err != nil {
+		file.Close()
+		return "", 0, fmt.Errorf("error writing report: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return "", 0, fmt.Errorf("error writing report: %v", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return "", 0, fmt.Errorf("error saving report: %v", err)
+	}
+
+	return path, rows, nil
+}
+
+// ExpireReports deletes the files of completed reports past their expiry time
+func (s *ReportService) ExpireReports() error {
+	var jobs []models.ReportJob
+	err := s.db.Where("status = ? AND expires_at < ?", models.ReportStatusCompleted, time.Now()).
+		Find(&jobs).Error
+	if err != nil {
+		return fmt.Errorf("error finding expired reports: %v", err)
+	}
+
+	for _, job := range jobs {
+		if err := os.Remove(job.FilePath); err != nil && !os.IsNotExist(err) {
+			s.logger.WithError(err).WithField("report_id", job.ID).Error("Failed to delete expired report")
+			continue
+		}
+		err := s.db.Model(&job).Updates(map[string]interface{}{
+			"status":    models.ReportStatusExpired,
+			"file_path": "",
+		}).Error
+		if err != nil {
+			return fmt.Errorf("error expiring report %d: %v", job.ID, err)
+		}
+	}
+
+	if len(jobs) > 0 {
+		s.logger.WithField("reports", len(jobs)).Info("Expired old reports")
+	}
+	return nil
+}
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"sales-analytics/internal/export"
+	"sales-analytics/internal/models"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// webhookTimeout bounds a single webhook delivery, including the upload of the report
+const webhookTimeout = 5 * time.Minute
+
+// ReportScheduleService runs report schedules on the application cron and delivers their output
+// to the drop directory or a webhook
+type ReportScheduleService struct {
+	db      *gorm.DB
+	reports *ReportService
+	cron    *cron.Cron
+	logger  *logrus.Logger
+	dropDir string
+	client  *http.Client
+
+	mu      sync.Mutex
+	entries map[uint]cron.EntryID
+	running sync.WaitGroup
+}
+
+func NewReportScheduleService(db *gorm.DB, reports *ReportService, scheduler *cron.Cron, logger *logrus.Logger, dropDir string) *ReportScheduleService {
+	return &ReportScheduleService{
+		db:      db,
+		reports: reports,
+		cron:    scheduler,
+		logger:  logger,
+		dropDir: dropDir,
+		client:  &http.Client{Timeout: webhookTimeout},
+		entries: make(map[uint]cron.EntryID),
+	}
+}
+
+// previousPeriod returns the first and last day of the last full day, week, month, quarter or
+// year before now
+func previousPeriod(now time.Time, granularity string) (time.Time, time.Time) {
+	year, month, day := now.Date()
+	current := truncatePeriod(time.Date(year, month, day, 0, 0, 0, 0, time.UTC), granularity)
+	end := current.AddDate(0, 0, -1)
+	return truncatePeriod(end, granularity), end
+}
+
+// reportFor returns the report spec a schedule runs at the given time
+func reportFor(schedule *models.ReportSchedule, now time.Time) models.ReportSpec {
+	spec := schedule.Report
+	if schedule.Period != "" {
+		startDate, endDate := previousPeriod(now, schedule.Period)
+		spec.StartDate = startDate.Format("2006-01-02")
+		spec.EndDate = endDate.Format("2006-01-02")
+	}
+	return spec
+}
+
+// ValidateSchedule checks that a schedule can be registered and run, defaulting the report
+// format to csv
+func (s *ReportScheduleService) ValidateSchedule(schedule *models.ReportSchedule) error {
+	if schedule.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if _, err := cron.ParseStandard(schedule.CronSpec); err != nil {
+		return fmt.Errorf("invalid cron_spec '%s': %v", schedule.CronSpec, err)
+	}
+	if schedule.Period != "" && !IsValidGranularity(schedule.Period) {
+		return fmt.Errorf("invalid period '%s', expected day, week, month, quarter or year", schedule.Period)
+	}
+
+	switch schedule.Delivery {
+	case models.ReportDeliveryDirectory:
+		schedule.WebhookURL = ""
+	case models.ReportDeliveryWebhook:
+		target, err := url.Parse(schedule.WebhookURL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return fmt.Errorf("invalid webhook_url '%s', expected an http or https URL", schedule.WebhookURL)
+		}
+	default:
+		return fmt.Errorf("invalid delivery '%s', expected directory or webhook", schedule.Delivery)
+	}
+
+	spec := reportFor(schedule, time.Now())
+	if err := s.reports.ValidateSpec(&spec); err != nil {
+		return err
+	}
+	schedule.Report.Format = spec.Format
+	return nil
+}
+
+// CreateSchedule stores a validated schedule and registers it with the cron
+func (s *ReportScheduleService) CreateSchedule(schedule *models.ReportSchedule) error {
+	schedule.Model = gorm.Model{}
+
+	// Register within the insert's transaction so a schedule the cron rejects is never saved
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(schedule).Error; err != nil {
+			return fmt.Errorf("error creating report schedule: %v", err)
+		}
+		return s.register(schedule)
+	})
+	if err != nil {
+		// The commit can fail after the schedule was registered
+		s.unregister(schedule.ID)
+		return err
+	}
+	return nil
+}
+
+// GetSchedule returns a single report schedule
+func (s *ReportScheduleService) GetSchedule(id uint) (*models.ReportSchedule, error) {
+	var schedule models.ReportSchedule
+	if err := s.db.First(&schedule, id).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// ListSchedules returns all report schedules in creation order
+func (s *ReportScheduleService) ListSchedules() ([]models.ReportSchedule, error) {
+	var schedules []models.ReportSchedule
+	if err := s.db.Order("id").Find(&schedules).Error; err != nil {
+		return nil, fmt.Errorf("error listing report schedules: %v", err)
+	}
+	return schedules, nil
+}
+
+// DeleteSchedule removes a schedule from the cron and soft-deletes it, keeping its run history
+func (s *ReportScheduleService) DeleteSchedule(id uint) error {
+	result := s.db.Delete(&models.ReportSchedule{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("error deleting report schedule: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	s.unregister(id)
+	return nil
+}
+
+// ListRuns returns the most recent runs of a schedule, newest first
+func (s *ReportScheduleService) ListRuns(scheduleID uint, limit, offset int) ([]models.ReportScheduleRun, int64, error) {
+	var (
+		runs  []models.ReportScheduleRun
+		total int64
+	)
+
+	query := s.db.Model(&models.ReportScheduleRun{}).Where("schedule_id = ?", scheduleID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("error counting report schedule runs: %v", err)
+	}
+
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&runs).Error; err != nil {
+		return nil, 0, fmt.Errorf("error listing report schedule runs: %v", err)
+	}
+
+	return runs, total, nil
+}
+
+// LoadSchedules marks runs interrupted by a previous shutdown as failed and registers every
+// stored schedule with the cron
+func (s *ReportScheduleService) LoadSchedules() error {
+	err := s.db.Model(&models.ReportScheduleRun{}).
+		Where("status = ?", models.ReportRunRunning).
+		Updates(map[string]interface{}{
+			"status":      models.ReportRunFailed,
+			"finished_at": time.Now(),
+			"error":       "run interrupted by application restart",
+		}).Error
+	if err != nil {
+		return fmt.Errorf("error failing interrupted report schedule runs: %v", err)
+	}
+
+	schedules, err := s.ListSchedules()
+	if err != nil {
+		return err
+	}
+	for i := range schedules {
+		if err := s.register(&schedules[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// register adds a schedule to the cron
+func (s *ReportScheduleService) register(schedule *models.ReportSchedule) error {
+	id := schedule.ID
+	entry, err := s.cron.AddFunc(schedule.CronSpec, func() {
+		if _, err := s.Trigger(id, models.ReportTriggerCron); err != nil {
+			s.logger.WithError(err).WithField("schedule_id", id).Error("Failed to run report schedule")
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("error scheduling report %d: %v", id, err)
+	}
+
+	s.mu.Lock()
+	s.entries[id] = entry
+	s.mu.Unlock()
+	return nil
+}
+
+// unregister removes a schedule from the cron, if it was added
+func (s *ReportScheduleService) unregister(id uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[id]; ok {
+		s.cron.Remove(entry)
+		delete(s.entries, id)
+	}
+}
+
+// Trigger records a new run of a schedule and generates and delivers the report in the background
+func (s *ReportScheduleService) Trigger(id uint, trigger string) (*models.ReportScheduleRun, error) {
+	schedule, err := s.GetSchedule(id)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := reportFor(schedule, time.Now())
+	run := &models.ReportScheduleRun{
+		ScheduleID: schedule.ID,
+		Trigger:    trigger,
+		Status:     models.ReportRunRunning,
+		StartDate:  spec.StartDate,
+		EndDate:    spec.EndDate,
+		StartedAt:  time.Now(),
+	}
+	if err := s.db.Create(run).Error; err != nil {
+		return nil, fmt.Errorf("error creating report schedule run: %v", err)
+	}
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		s.execute(schedule, spec, run)
+	}()
+
+	return run, nil
+}
+
+// Wait blocks until all runs in progress have finished
+func (s *ReportScheduleService) Wait() {
+	s.running.Wait()
+}
+
+// execute generates and delivers the report of a run and records the outcome
+func (s *ReportScheduleService) execute(schedule *models.ReportSchedule, spec models.ReportSpec, run *models.ReportScheduleRun) {
+	logger := s.logger.WithFields(logrus.Fields{"schedule_id": schedule.ID, "run_id": run.ID})
+	logger.Info("Running report schedule")
+
+	err := s.deliver(schedule, spec, run)
+
+	now := time.Now()
+	run.FinishedAt = &now
+	if err != nil {
+		logger.WithError(err).Error("Report schedule run failed")
+		run.Status = models.ReportRunFailed
+		run.Error = err.Error()
+	} else {
+		logger.WithField("destination", run.Destination).Info("Report delivered")
+		run.Status = models.ReportRunCompleted
+	}
+
+	if err := s.db.Save(run).Error; err != nil {
+		logger.WithError(err).Error("Failed to save report schedule run")
+	}
+}
+
+// deliver writes the report to the drop directory, or to a temporary file that is posted to the
+// webhook
+func (s *ReportScheduleService) deliver(schedule *models.ReportSchedule, spec models.ReportSpec, run *models.ReportScheduleRun) error {
+	name := fmt.Sprintf("schedule-%d-%s-%s", schedule.ID, spec.StartDate, spec.EndDate)
+
+	if schedule.Delivery == models.ReportDeliveryDirectory {
+		path, rows, err := s.reports.WriteFile(spec, s.dropDir, name)
+		if err != nil {
+			return err
+		}
+		run.Destination = path
+		run.RowCount = rows
+		if info, err := os.Stat(path); err == nil {
+			run.FileSize = info.Size()
+		}
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", "report-schedule-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path, rows, err := s.reports.WriteFile(spec, dir, name)
+	if err != nil {
+		return err
+	}
+	run.Destination = schedule.WebhookURL
+	run.RowCount = rows
+
+	return s.post(schedule, spec, run, path)
+}
+
+// post uploads a report file to the schedule's webhook. Any response outside 2xx is a failure.
+func (s *ReportScheduleService) post(schedule *models.ReportSchedule, spec models.ReportSpec, run *models.ReportScheduleRun, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening report file: %v", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("error reading report file: %v", err)
+	}
+	run.FileSize = info.Size()
+
+	req, err := http.NewRequest(http.MethodPost, schedule.WebhookURL, file)
+	if err != nil {
+		return fmt.Errorf("error creating webhook request: %v", err)
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", export.ContentType(spec.Format))
+	req.Header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filepath.Base(path)))
+	req.Header.Set("X-Report-Schedule-ID", strconv.FormatUint(uint64(schedule.ID), 10))
+	req.Header.Set("X-Report-Run-ID", strconv.FormatUint(uint64(run.ID), 10))
+	req.Header.Set("X-Report-Start-Date", spec.StartDate)
+	req.Header.Set("X-Report-End-Date", spec.EndDate)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error posting report to webhook: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	run.ResponseStatus = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -2,85 +2,144 @@ package services
 
 import (
 	"fmt"
-	"time"
 
+	"sales-analytics/internal/export"
 	"sales-analytics/internal/models"
 
 	"gorm.io/gorm"
 )
 
 type RevenueService struct {
-	db *gorm.DB
+	db      *gorm.DB
+	rollups *RollupService
 }
 
-func NewRevenueService(db *gorm.DB) *RevenueService {
-	return &RevenueService{db: db}
+func NewRevenueService(db *gorm.DB, rollups *RollupService) *RevenueService {
+	return &RevenueService{db: db, rollups: rollups}
 }
 
-func (s *RevenueService) GetTotalRevenue(startDate, endDate time.Time) (*models.RevenueResponse, error) {
+func (s *RevenueService) GetTotalRevenue(filter models.RevenueFilter) (*models.RevenueResponse, error) {
 	var totalRevenue float64
 
-	err := s.db.Model(&models.Order{}).
-		Joins("JOIN products ON products.product_id = orders.product_id").
-		Where("orders.date_of_sale BETWEEN ? AND ?", startDate, endDate).
-		Select("COALESCE(SUM((products.unit_price * orders.quantity) - orders.discount + orders.shipping_cost), 0) as total_revenue").
+	source := s.totalSource(filter)
+	err := source.query.
+		Select("COALESCE(SUM(" + source.revenue + "), 0) as total_revenue").
 		Scan(&totalRevenue).Error
 
 	if err != nil {
 		return nil, fmt.Errorf("error calculating total revenue: %v", err)
 	}
 
-	return &models.RevenueResponse{TotalRevenue: totalRevenue}, nil
+	return &models.RevenueResponse{TotalRevenue: totalRevenue, RevenueDefinition: filter.Revenue}, nil
 }
 
-func (s *RevenueService) GetRevenueByProduct(startDate, endDate time.Time) ([]models.ProductRevenue, error) {
-	var results []models.ProductRevenue
-
-	err := s.db.Model(&models.Product{}).
-		Select("products.product_id, products.name as product_name, COALESCE(SUM((products.unit_price * orders.quantity) - orders.discount + orders.shipping_cost), 0) as revenue").
-		Joins("LEFT JOIN orders ON products.product_id = orders.product_id AND orders.date_of_sale BETWEEN ? AND ?", startDate, endDate).
-		Group("products.product_id, products.name").
-		Order("revenue DESC").
-		Scan(&results).Error
-
+func (s *RevenueService) GetRevenueByProduct(filter models.RevenueFilter, page models.PageRequest) (*models.Page[models.ProductRevenue], error) {
+	results, err := paginate[models.ProductRevenue](s.db, s.productBreakdown(filter, page), breakdownColumns{key: "product_id", name: "product_name"}, page)
 	if err != nil {
 		return nil, fmt.Errorf("error querying revenue by product: %v", err)
 	}
+	results.RevenueDefinition = &filter.Revenue
 
 	return results, nil
 }
 
-func (s *RevenueService) GetRevenueByCategory(startDate, endDate time.Time) ([]models.CategoryRevenue, error) {
-	var results []models.CategoryRevenue
+// RevenueByProductTable returns the requested page of revenue by product as an export table
+// streamed from the database
+func (s *RevenueService) RevenueByProductTable(filter models.RevenueFilter, page models.PageRequest) (*export.Table, error) {
+	return pageTable[models.ProductRevenue](s.db, s.productBreakdown(filter, page), breakdownColumns{key: "product_id", name: "product_name"}, page)
+}
 
-	err := s.db.Model(&models.Product{}).
-		Select("products.category, COALESCE(SUM((products.unit_price * orders.quantity) - orders.discount + orders.shipping_cost), 0) as revenue").
-		Joins("LEFT JOIN orders ON products.product_id = orders.product_id AND orders.date_of_sale BETWEEN ? AND ?", startDate, endDate).
-		Group("products.category").
-		Order("revenue DESC").
-		Scan(&results).Error
+// productBreakdown selects the revenue and quantity of every product matching the filter
+func (s *RevenueService) productBreakdown(filter models.RevenueFilter, page models.PageRequest) func() *gorm.DB {
+	return func() *gorm.DB {
+		source := s.productSource(filter)
+		sales := source.query.
+			Select(source.productID + " as product_id, SUM(" + source.revenue + ") as revenue, SUM(" + source.quantity + ") as quantity").
+			Group(source.productID)
+
+		query := s.db.Model(&models.Product{}).
+			Select("products.product_id, products.name as product_name, COALESCE(sales.revenue, 0) as revenue, COALESCE(sales.quantity, 0) as quantity").
+			Joins("LEFT JOIN (?) sales ON sales.product_id = products.product_id", sales).
+			Scopes(productFilter(filter))
+		if !page.IncludeZero {
+			query = query.Where("sales.product_id IS NOT NULL")
+		}
+		return query
+	}
+}
 
+func (s *RevenueService) GetRevenueByCategory(filter models.RevenueFilter, page models.PageRequest) (*models.Page[models.CategoryRevenue], error) {
+	results, err := paginate[models.CategoryRevenue](s.db, s.categoryBreakdown(filter, page), breakdownColumns{key: "category", name: "category"}, page)
 	if err != nil {
 		return nil, fmt.Errorf("error querying revenue by category: %v", err)
 	}
+	results.RevenueDefinition = &filter.Revenue
 
 	return results, nil
 }
 
-func (s *RevenueService) GetRevenueByRegion(startDate, endDate time.Time) ([]models.RegionRevenue, error) {
-	var results []models.RegionRevenue
+// RevenueByCategoryTable returns the requested page of revenue by category as an export table
+// streamed from the database
+func (s *RevenueService) RevenueByCategoryTable(filter models.RevenueFilter, page models.PageRequest) (*export.Table, error) {
+	return pageTable[models.CategoryRevenue](s.db, s.categoryBreakdown(filter, page), breakdownColumns{key: "category", name: "category"}, page)
+}
 
-	err := s.db.Model(&models.Customer{}).
-		Select("customers.region, COALESCE(SUM((products.unit_price * orders.quantity) - orders.discount + orders.shipping_cost), 0) as revenue").
-		Joins("LEFT JOIN orders ON customers.customer_id = orders.customer_id AND orders.date_of_sale BETWEEN ? AND ?", startDate, endDate).
-		Joins("LEFT JOIN products ON products.product_id = orders.product_id").
-		Group("customers.region").
-		Order("revenue DESC").
-		Scan(&results).Error
+// categoryBreakdown selects the revenue and quantity of every category matching the filter
+func (s *RevenueService) categoryBreakdown(filter models.RevenueFilter, page models.PageRequest) func() *gorm.DB {
+	return func() *gorm.DB {
+		categories := s.db.Model(&models.Product{}).
+			Distinct("products.category").
+			Scopes(productFilter(filter))
+
+		source := s.productSource(filter)
+		sales := source.query.
+			Select("products.category, SUM(" + source.revenue + ") as revenue, SUM(" + source.quantity + ") as quantity").
+			Group("products.category")
+
+		query := s.db.Table("(?) categories", categories).
+			Select("categories.category, COALESCE(sales.revenue, 0) as revenue, COALESCE(sales.quantity, 0) as quantity").
+			Joins("LEFT JOIN (?) sales ON sales.category = categories.category", sales)
+		if !page.IncludeZero {
+			query = query.Where("sales.category IS NOT NULL")
+		}
+		return query
+	}
+}
 
+func (s *RevenueService) GetRevenueByRegion(filter models.RevenueFilter, page models.PageRequest) (*models.Page[models.RegionRevenue], error) {
+	results, err := paginate[models.RegionRevenue](s.db, s.regionBreakdown(filter, page), breakdownColumns{key: "region", name: "region"}, page)
 	if err != nil {
 		return nil, fmt.Errorf("error querying revenue by region: %v", err)
 	}
+	results.RevenueDefinition = &filter.Revenue
 
 	return results, nil
 }
+
+// RevenueByRegionTable returns the requested page of revenue by region as an export table
+// streamed from the database
+func (s *RevenueService) RevenueByRegionTable(filter models.RevenueFilter, page models.PageRequest) (*export.Table, error) {
+	return pageTable[models.RegionRevenue](s.db, s.regionBreakdown(filter, page), breakdownColumns{key: "region", name: "region"}, page)
+}
+
+// regionBreakdown selects the revenue and quantity of every region matching the filter
+func (s *RevenueService) regionBreakdown(filter models.RevenueFilter, page models.PageRequest) func() *gorm.DB {
+	return func() *gorm.DB {
+		regions := s.db.Model(&models.Customer{}).
+			Distinct("customers.region").
+			Scopes(customerFilter(filter))
+
+		source := s.regionSource(filter)
+		sales := source.query.
+			Select(source.region + " as region, SUM(" + source.revenue + ") as revenue, SUM(" + source.quantity + ") as quantity").
+			Group(source.region)
+
+		query := s.db.Table("(?) regions", regions).
+			Select("regions.region, COALESCE(sales.revenue, 0) as revenue, COALESCE(sales.quantity, 0) as quantity").
+			Joins("LEFT JOIN (?) sales ON sales.region = regions.region", sales)
+		if !page.IncludeZero {
+			query = query.Where("sales.region IS NOT NULL")
+		}
+		return query
+	}
+}
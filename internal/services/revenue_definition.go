@@ -0,0 +1,54 @@
+package services
+
+import (
+	"fmt"
+
+	"sales-analytics/internal/models"
+)
+
+// grossSalesExpr computes the value of an order before discounts and shipping
+const grossSalesExpr = "(orders.unit_price * orders.quantity)"
+
+// NewRevenueDefinition validates and returns a revenue definition
+func NewRevenueDefinition(basis, discountType string, includeShipping bool) (models.RevenueDefinition, error) {
+	if basis != models.RevenueBasisGross && basis != models.RevenueBasisNet {
+		return models.RevenueDefinition{}, fmt.Errorf("unsupported revenue basis '%s'", basis)
+	}
+	if discountType != models.DiscountTypeAbsolute && discountType != models.DiscountTypePercentage {
+		return models.RevenueDefinition{}, fmt.Errorf("unsupported discount type '%s'", discountType)
+	}
+
+	return models.RevenueDefinition{
+		Basis:           basis,
+		DiscountType:    discountType,
+		IncludeShipping: includeShipping,
+	}, nil
+}
+
+// discountExpr computes the discount amount of an order. Percentage discounts are stored as
+// whole percentages, so 15 means 15% of gross sales.
+func discountExpr(def models.RevenueDefinition) string {
+	if def.DiscountType == models.DiscountTypePercentage {
+		return "(" + grossSalesExpr + " * orders.discount / 100)"
+	}
+	return "orders.discount"
+}
+
+// revenueExpr computes the revenue of an order under the given definition, from the unit price
+// captured at the time of sale
+func revenueExpr(def models.RevenueDefinition) string {
+	return revenueFormula(def, grossSalesExpr, discountExpr(def), "orders.shipping_cost")
+}
+
+// revenueFormula combines gross sales, discount and shipping expressions into revenue under the
+// given definition
+func revenueFormula(def models.RevenueDefinition, gross, discount, shipping string) string {
+	expr := gross
+	if def.Basis == models.RevenueBasisNet {
+		expr += " - " + discount
+	}
+	if def.IncludeShipping {
+		expr += " + " + shipping
+	}
+	return expr
+}
@@ -0,0 +1,330 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"sales-analytics/internal/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// RollupService maintains the daily revenue rollups that revenue queries are served from when
+// the filter allows it
+type RollupService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+
+	// refreshLock serialises rebuilds; ready is set once the rollups match the orders table
+	refreshLock sync.Mutex
+	ready       atomic.Bool
+}
+
+func NewRollupService(db *gorm.DB, logger *logrus.Logger) *RollupService {
+	return &RollupService{db: db, logger: logger}
+}
+
+// Ready reports whether the rollups are up to date and can be queried. Until the first rebuild
+// succeeds, and after a failed one, revenue queries read the orders table instead.
+func (s *RollupService) Ready() bool {
+	return s.ready.Load()
+}
+
+// rollupTable describes a daily rollup and the order column its rows are keyed by
+type rollupTable struct {
+	name string
+	// key is the rollup's key column and source the expression it is computed from
+	key    string
+	source string
+}
+
+// The daily rollups
+var (
+	productRollup = rollupTable{name: "daily_product_revenue", key: "product_id", source: "orders.product_id"}
+	regionRollup  = rollupTable{name: "daily_region_revenue", key: "region", source: "customers.region"}
+	rollupTables  = []rollupTable{productRollup, regionRollup}
+)
+
+// touched names the temporary table collecting the rows of the rollup a batch touches
+func (r rollupTable) touched() string {
+	return "touched_" + r.name
+}
+
+// Refresh rebuilds the rollups from the orders table in a single transaction, so queries keep
+// reading the previous rollups until the new ones are complete
+func (s *RollupService) Refresh() error {
+	s.refreshLock.Lock()
+	defer s.refreshLock.Unlock()
+
+	err := s.db.Transaction(rebuildRollups)
+
+	s.ready.Store(err == nil)
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("Daily revenue rollups refreshed")
+	return nil
+}
+
+// rebuildWithin runs transaction, which must rebuild the rollups with rebuildRollups alongside
+// its other writes, while holding the refresh lock. The rollups are ready once it commits.
+func (s *RollupService) rebuildWithin(transaction func() error) error {
+	s.refreshLock.Lock()
+	defer s.refreshLock.Unlock()
+
+	if err := transaction(); err != nil {
+		return err
+	}
+	s.ready.Store(true)
+	return nil
+}
+
+// MarkStale stops revenue queries from reading the rollups until the next successful rebuild,
+// for when the orders table changed in a way the rollups did not follow
+func (s *RollupService) MarkStale() {
+	s.ready.Store(false)
+}
+
+// rebuildRollups recomputes every rollup row from the orders table
+func rebuildRollups(tx *gorm.DB) error {
+	for _, r := range rollupTables {
+		if err := tx.Exec("DELETE FROM " + r.name).Error; err != nil {
+			return fmt.Errorf("error clearing %s: %v", r.name, err)
+		}
+		if err := tx.Exec(fmt.Sprintf(`
+			INSERT INTO %s (date, %s, %s)
+			SELECT (orders.date_of_sale AT TIME ZONE 'UTC')::date, %s, %s
+			FROM orders
+			JOIN products ON products.product_id = orders.product_id
+			JOIN customers ON customers.customer_id = orders.customer_id
+			WHERE orders.deleted_at IS NULL
+			GROUP BY 1, 2
+		`, r.name, r.key, rollupColumns, r.source, rollupAggregates)).Error; err != nil {
+			return fmt.Errorf("error building %s: %v", r.name, err)
+		}
+	}
+	return nil
+}
+
+// trackBatch prepares the incremental refresh of the rollups for a batch and returns the IDs of
+// the batch's customers that move to another region. It must run in the batch's transaction
+// before customers are upserted, so the rows of the regions they leave are recomputed too.
+func (s *RollupService) trackBatch(tx *gorm.DB, customers []models.Customer) ([]string, error) {
+	// The temporary tables live on the transaction's connection and are dropped on commit
+	for _, r := range rollupTables {
+		if err := tx.Exec(fmt.Sprintf(
+			"CREATE TEMPORARY TABLE %s (date date, key varchar(100), PRIMARY KEY (date, key)) ON COMMIT DROP",
+			r.touched(),
+		)).Error; err != nil {
+			return nil, fmt.Errorf("error creating touched rollup rows table: %v", err)
+		}
+	}
+
+	regions := make(map[string]string, len(customers))
+	ids := make([]string, 0, len(customers))
+	for _, customer := range customers {
+		regions[customer.CustomerID] = customer.Region
+		ids = append(ids, customer.CustomerID)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var existing []models.Customer
+	if err := tx.Unscoped().Select("customer_id, region").Where("customer_id IN ?", ids).Find(&existing).Error; err != nil {
+		return nil, fmt.Errorf("error reading customer regions: %v", err)
+	}
+	var moved []string
+	for _, customer := range existing {
+		if customer.Region != regions[customer.CustomerID] {
+			moved = append(moved, customer.CustomerID)
+		}
+	}
+
+	if len(moved) > 0 {
+		if err := touchRollup(tx, regionRollup, "orders.customer_id IN ?", moved); err != nil {
+			return nil, err
+		}
+	}
+	return moved, nil
+}
+
+// refreshBatch recomputes the rollup rows holding the orders of a batch, and those of the
+// customers that moved to another region, once the batch has been written. It must run in the
+// batch's transaction after trackBatch.
+func (s *RollupService) refreshBatch(tx *gorm.DB, moved []string, orders []models.Order) error {
+	ids := make([]string, len(orders))
+	for i, order := range orders {
+		ids[i] = order.OrderID
+	}
+
+	for _, r := range rollupTables {
+		if err := touchRollup(tx, r, "orders.order_id IN ?", ids); err != nil {
+			return err
+		}
+	}
+	if len(moved) > 0 {
+		if err := touchRollup(tx, regionRollup, "orders.customer_id IN ?", moved); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range rollupTables {
+		if err := refreshTouched(tx, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// touchRollup records the rows of a rollup holding the live orders matching where
+func touchRollup(tx *gorm.DB, r rollupTable, where string, args ...interface{}) error {
+	if err := tx.Exec(fmt.Sprintf(`
+		INSERT INTO %s (date, key)
+		SELECT DISTINCT (orders.date_of_sale AT TIME ZONE 'UTC')::date, %s
+		FROM orders
+		JOIN customers ON customers.customer_id = orders.customer_id
+		WHERE orders.deleted_at IS NULL AND %s
+		ON CONFLICT DO NOTHING
+	`, r.touched(), r.source, where), args...).Error; err != nil {
+		return fmt.Errorf("error collecting touched %s rows: %v", r.name, err)
+	}
+	return nil
+}
+
+// refreshTouched recomputes the touched rows of a rollup from the orders of their day. Rows left
+// without orders are removed.
+func refreshTouched(tx *gorm.DB, r rollupTable) error {
+	if err := tx.Exec(fmt.Sprintf(`
+		DELETE FROM %[1]s USING %[2]s touched
+		WHERE %[1]s.date = touched.date AND %[1]s.%[3]s = touched.key
+	`, r.name, r.touched(), r.key)).Error; err != nil {
+		return fmt.Errorf("error clearing touched %s rows: %v", r.name, err)
+	}
+
+	// Orders are looked up by their time of sale, which is indexed
+	if err := tx.Exec(fmt.Sprintf(`
+		INSERT INTO %[1]s (date, %[3]s, %[4]s)
+		SELECT touched.date, touched.key, %[5]s
+		FROM %[2]s touched
+		JOIN orders ON orders.date_of_sale >= touched.date::timestamp AT TIME ZONE 'UTC'
+			AND orders.date_of_sale < (touched.date + 1)::timestamp AT TIME ZONE 'UTC'
+		JOIN products ON products.product_id = orders.product_id
+		JOIN customers ON customers.customer_id = orders.customer_id
+		WHERE orders.deleted_at IS NULL AND %[6]s = touched.key
+		GROUP BY 1, 2
+	`, r.name, r.touched(), r.key, rollupColumns, rollupAggregates, r.source)).Error; err != nil {
+		return fmt.Errorf("error refreshing touched %s rows: %v", r.name, err)
+	}
+	return nil
+}
+
+// rollupColumns and rollupAggregates list the models.DailyRevenue columns and how they are
+// computed from orders
+const (
+	rollupColumns    = "gross_sales, discount, percentage_discount, shipping_cost, quantity, order_count"
+	rollupAggregates = "COALESCE(SUM(" + grossSalesExpr + "), 0), SUM(orders.discount), " +
+		"COALESCE(SUM(" + grossSalesExpr + " * orders.discount / 100), 0), SUM(orders.shipping_cost), " +
+		"SUM(orders.quantity), COUNT(*)"
+)
+
+// revenueSource is the table revenue is aggregated from: orders joined to their product and
+// customer, or a daily rollup that can answer the filter. The expressions are per row and meant
+// to be summed.
+type revenueSource struct {
+	query     *gorm.DB
+	revenue   string
+	quantity  string
+	productID string
+	region    string
+}
+
+// ordersSource aggregates revenue from the orders table, which answers every filter
+func ordersSource(db *gorm.DB, f models.RevenueFilter) revenueSource {
+	return revenueSource{
+		query:     filteredOrders(db, f),
+		revenue:   revenueExpr(f.Revenue),
+		quantity:  "orders.quantity",
+		productID: "orders.product_id",
+		region:    "customers.region",
+	}
+}
+
+// rollupRevenueExpr computes revenue under the given definition from a rollup row
+func rollupRevenueExpr(def models.RevenueDefinition) string {
+	discount := "rollup.discount"
+	if def.DiscountType == models.DiscountTypePercentage {
+		discount = "rollup.percentage_discount"
+	}
+	return revenueFormula(def, "rollup.gross_sales", discount, "rollup.shipping_cost")
+}
+
+// rollupDates restricts a rollup to the filter's date range. Dates are passed as plain dates
+// so the session time zone does not shift them.
+func rollupDates(f models.RevenueFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("rollup.date BETWEEN ? AND ?", f.StartDate.Format("2006-01-02"), f.EndDate.Format("2006-01-02"))
+	}
+}
+
+// productRollupCovers reports whether the product rollup can answer the filter: it cannot
+// restrict customers, regions, payment methods or quantities
+func (s *RevenueService) productRollupCovers(f models.RevenueFilter) bool {
+	return s.rollups != nil && s.rollups.Ready() &&
+		len(f.Regions) == 0 && len(f.CustomerIDs) == 0 && len(f.PaymentMethods) == 0 &&
+		f.MinQuantity == nil && f.MaxQuantity == nil
+}
+
+// regionRollupCovers reports whether the region rollup can answer the filter: it can only
+// restrict regions
+func (s *RevenueService) regionRollupCovers(f models.RevenueFilter) bool {
+	return s.rollups != nil && s.rollups.Ready() &&
+		len(f.Categories) == 0 && len(f.ProductIDs) == 0 && len(f.CustomerIDs) == 0 && len(f.PaymentMethods) == 0 &&
+		f.MinQuantity == nil && f.MaxQuantity == nil
+}
+
+// productSource returns the source for product and category revenue
+func (s *RevenueService) productSource(f models.RevenueFilter) revenueSource {
+	if !s.productRollupCovers(f) {
+		return ordersSource(s.db, f)
+	}
+
+	return revenueSource{
+		query: s.db.Table("daily_product_revenue AS rollup").
+			Joins("JOIN products ON products.product_id = rollup.product_id").
+			Scopes(rollupDates(f), productFilter(f)),
+		revenue:   rollupRevenueExpr(f.Revenue),
+		quantity:  "rollup.quantity",
+		productID: "rollup.product_id",
+	}
+}
+
+// regionSource returns the source for region revenue
+func (s *RevenueService) regionSource(f models.RevenueFilter) revenueSource {
+	if !s.regionRollupCovers(f) {
+		return ordersSource(s.db, f)
+	}
+
+	query := s.db.Table("daily_region_revenue AS rollup").Scopes(rollupDates(f))
+	if len(f.Regions) > 0 {
+		query = query.Where("rollup.region IN ?", f.Regions)
+	}
+
+	return revenueSource{
+		query:    query,
+		revenue:  rollupRevenueExpr(f.Revenue),
+		quantity: "rollup.quantity",
+		region:   "rollup.region",
+	}
+}
+
+// totalSource returns the source for total revenue, preferring the product rollup
+func (s *RevenueService) totalSource(f models.RevenueFilter) revenueSource {
+	if s.productRollupCovers(f) {
+		return s.productSource(f)
+	}
+	return s.regionSource(f)
+}
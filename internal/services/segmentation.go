@@ -0,0 +1,210 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"sales-analytics/internal/export"
+	"sales-analytics/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// SegmentOther is assigned to customers that match no segment rule
+const SegmentOther = "other"
+
+// SegmentRule assigns a label to customers whose recency, frequency and monetary scores all
+// fall within the given inclusive ranges. Scores run from 1 to 5, where 5 is best; zero bounds
+// default to the full range.
+type SegmentRule struct {
+	Label        string `json:"label"`
+	RecencyMin   int    `json:"recency_min"`
+	RecencyMax   int    `json:"recency_max"`
+	FrequencyMin int    `json:"frequency_min"`
+	FrequencyMax int    `json:"frequency_max"`
+	MonetaryMin  int    `json:"monetary_min"`
+	MonetaryMax  int    `json:"monetary_max"`
+}
+
+// DefaultSegmentRules are evaluated in order; the first matching rule wins
+var DefaultSegmentRules = []SegmentRule{
+	{Label: "champions", RecencyMin: 4, RecencyMax: 5, FrequencyMin: 4, FrequencyMax: 5, MonetaryMin: 4, MonetaryMax: 5},
+	{Label: "loyal", RecencyMin: 3, RecencyMax: 5, FrequencyMin: 3, FrequencyMax: 5},
+	{Label: "new", RecencyMin: 4, RecencyMax: 5, FrequencyMin: 1, FrequencyMax: 1},
+	{Label: "potential_loyalists", RecencyMin: 3, RecencyMax: 5, FrequencyMin: 1, FrequencyMax: 2},
+	{Label: "at_risk", RecencyMin: 1, RecencyMax: 2, FrequencyMin: 3, FrequencyMax: 5},
+	{Label: "hibernating", RecencyMin: 2, RecencyMax: 2, FrequencyMin: 1, FrequencyMax: 2},
+	{Label: "lost", RecencyMin: 1, RecencyMax: 1, FrequencyMin: 1, FrequencyMax: 2},
+}
+
+// LoadSegmentRules reads segment rules from a JSON file, or returns the defaults if path is empty
+func LoadSegmentRules(path string) ([]SegmentRule, error) {
+	if path == "" {
+		return DefaultSegmentRules, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading segment rules file: %v", err)
+	}
+
+	var rules []SegmentRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("error parsing segment rules file: %v", err)
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("segment rules file defines no segments")
+	}
+
+	seen := make(map[string]bool, len(rules))
+	for i, rule := range rules {
+		if strings.TrimSpace(rule.Label) == "" {
+			return nil, fmt.Errorf("segment rule %d has no label", i+1)
+		}
+		if rule.Label == SegmentOther || seen[rule.Label] {
+			return nil, fmt.Errorf("duplicate segment label '%s'", rule.Label)
+		}
+		seen[rule.Label] = true
+	}
+
+	return rules, nil
+}
+
+// scoreBounds returns the inclusive score range of a rule, defaulting unset bounds to 1..5
+func scoreBounds(min, max int) (int, int) {
+	if min <= 0 {
+		min = 1
+	}
+	if max <= 0 {
+		max = 5
+	}
+	return min, max
+}
+
+type SegmentationService struct {
+	db    *gorm.DB
+	rules []SegmentRule
+}
+
+func NewSegmentationService(db *gorm.DB, rules []SegmentRule) *SegmentationService {
+	return &SegmentationService{
+		db:    db,
+		rules: rules,
+	}
+}
+
+// IsValidSegment reports whether label is one of the configured segments
+func (s *SegmentationService) IsValidSegment(label string) bool {
+	if label == SegmentOther {
+		return true
+	}
+	for _, rule := range s.rules {
+		if rule.Label == label {
+			return true
+		}
+	}
+	return false
+}
+
+// segmented scores every customer with orders on or before asOf into quintiles of recency,
+// frequency and monetary value and labels them with the first matching segment rule
+func (s *SegmentationService) segmented(asOf time.Time, revenue models.RevenueDefinition) *gorm.DB {
+	stats := s.db.Model(&models.Order{}).
+		Select("orders.customer_id, MAX(orders.date_of_sale) as last_purchase, COUNT(*) as order_count, "+
+			"SUM(orders.quantity) as quantity, SUM("+revenueExpr(revenue)+") as revenue").
+		Where("orders.date_of_sale <= ?", asOf).
+		Group("orders.customer_id")
+
+	scored := s.db.Table("(?) stats", stats).
+		Select("stats.*, " +
+			quintile("stats.last_purchase") + " as recency_score, " +
+			quintile("stats.order_count") + " as frequency_score, " +
+			quintile("stats.revenue") + " as monetary_score")
+
+	var (
+		segmentCase strings.Builder
+		args        []interface{}
+	)
+	segmentCase.WriteString("CASE")
+	for _, rule := range s.rules {
+		rMin, rMax := scoreBounds(rule.RecencyMin, rule.RecencyMax)
+		fMin, fMax := scoreBounds(rule.FrequencyMin, rule.FrequencyMax)
+		mMin, mMax := scoreBounds(rule.MonetaryMin, rule.MonetaryMax)
+		segmentCase.WriteString(" WHEN scored.recency_score BETWEEN ? AND ? AND scored.frequency_score BETWEEN ? AND ? AND scored.monetary_score BETWEEN ? AND ? THEN ?")
+		args = append(args, rMin, rMax, fMin, fMax, mMin, mMax, rule.Label)
+	}
+	segmentCase.WriteString(" ELSE ? END")
+	args = append(args, SegmentOther, asOf)
+
+	return s.db.Table("(?) scored", scored).
+		Select("scored.*, customers.name as customer_name, customers.region, "+
+			segmentCase.String()+" as segment, "+
+			"(CAST(? AS date) - CAST(scored.last_purchase AS date)) as recency_days", args...).
+		Joins("JOIN customers ON customers.customer_id = scored.customer_id AND customers.deleted_at IS NULL")
+}
+
+// quintile scores a value from 1 to 5 by its percentile rank among all customers. Unlike NTILE,
+// equal values always get the same score, so scores do not change between queries.
+func quintile(column string) string {
+	return fmt.Sprintf("LEAST(5, 1 + FLOOR(PERCENT_RANK() OVER (ORDER BY %s) * 5))::int", column)
+}
+
+// GetSegmentCounts returns the number of customers in each segment as of the reference date.
+// Every configured segment is listed, including empty ones.
+func (s *SegmentationService) GetSegmentCounts(asOf time.Time, revenue models.RevenueDefinition) (*models.SegmentCounts, error) {
+	var rows []models.SegmentCount
+
+	err := s.db.Table("(?) segmented", s.segmented(asOf, revenue)).
+		Select("segmented.segment, COUNT(*) as customers").
+		Group("segmented.segment").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("error counting customer segments: %v", err)
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Segment] = row.Customers
+	}
+
+	result := &models.SegmentCounts{
+		AsOf:              asOf,
+		RevenueDefinition: revenue,
+		Segments:          make([]models.SegmentCount, 0, len(s.rules)+1),
+	}
+	for _, rule := range s.rules {
+		result.Segments = append(result.Segments, models.SegmentCount{Segment: rule.Label, Customers: counts[rule.Label]})
+	}
+	result.Segments = append(result.Segments, models.SegmentCount{Segment: SegmentOther, Customers: counts[SegmentOther]})
+
+	return result, nil
+}
+
+// GetSegmentMembers returns a page of the customers in a segment as of the reference date
+func (s *SegmentationService) GetSegmentMembers(segment string, asOf time.Time, revenue models.RevenueDefinition, page models.PageRequest) (*models.Page[models.SegmentMember], error) {
+	results, err := paginate[models.SegmentMember](s.db, s.segmentMembers(segment, asOf, revenue), breakdownColumns{key: "customer_id", name: "customer_name"}, page)
+	if err != nil {
+		return nil, fmt.Errorf("error querying segment members: %v", err)
+	}
+	results.RevenueDefinition = &revenue
+
+	return results, nil
+}
+
+// SegmentMembersTable returns the requested page of a segment's customers as an export table
+// streamed from the database
+func (s *SegmentationService) SegmentMembersTable(segment string, asOf time.Time, revenue models.RevenueDefinition, page models.PageRequest) (*export.Table, error) {
+	return pageTable[models.SegmentMember](s.db, s.segmentMembers(segment, asOf, revenue), breakdownColumns{key: "customer_id", name: "customer_name"}, page)
+}
+
+// segmentMembers selects the customers in a segment as of the reference date
+func (s *SegmentationService) segmentMembers(segment string, asOf time.Time, revenue models.RevenueDefinition) func() *gorm.DB {
+	return func() *gorm.DB {
+		return s.db.Table("(?) segmented", s.segmented(asOf, revenue)).
+			Select("segmented.*").
+			Where("segmented.segment = ?", segment)
+	}
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"sales-analytics/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// fingerprintBlock is the number of bytes hashed at the start of a file and before its loaded
+// offset
+const fingerprintBlock = 64 * 1024
+
+// loadPlan describes where a refresh starts reading its CSV file. Incremental loads resume after
+// the last loaded row with the header remembered from the full load.
+type loadPlan struct {
+	mode   string
+	offset int64
+	line   int
+	header []string
+	reason string
+}
+
+// fingerprint hashes the first block of a file and the block ending at offset. Rows are only
+// ever appended after offset, so a different fingerprint means the loaded part was rewritten.
+func fingerprint(file *os.File, offset int64) (string, error) {
+	hash := sha256.New()
+
+	head := min(offset, fingerprintBlock)
+	if _, err := io.Copy(hash, io.NewSectionReader(file, 0, head)); err != nil {
+		return "", fmt.Errorf("error fingerprinting CSV file: %v", err)
+	}
+	tail := max(head, offset-fingerprintBlock)
+	if _, err := io.Copy(hash, io.NewSectionReader(file, tail, offset-tail)); err != nil {
+		return "", fmt.Errorf("error fingerprinting CSV file: %v", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// decodeRecord parses a single CSV record encoded by encodeRecord
+func decodeRecord(raw string) ([]string, error) {
+	return csv.NewReader(strings.NewReader(raw)).Read()
+}
+
+// planLoad decides whether a refresh can load incrementally. It falls back to a full load when
+// the file was never loaded, has been truncated or rewritten, or a full load was requested.
+func (s *LoaderService) planLoad(file *os.File, path string, size int64, forceFull bool) (loadPlan, error) {
+	if forceFull {
+		return loadPlan{mode: models.RefreshModeFull, reason: "full refresh requested"}, nil
+	}
+
+	var state models.SourceFile
+	if err := s.db.Where("path = ?", path).Limit(1).Find(&state).Error; err != nil {
+		return loadPlan{}, fmt.Errorf("error reading CSV load state: %v", err)
+	}
+	return planFromState(file, size, state)
+}
+
+// planFromState decides whether a file of the given size can be loaded incrementally from the
+// state saved by the previous load. A zero state means the file was never loaded.
+func planFromState(file *os.File, size int64, state models.SourceFile) (loadPlan, error) {
+	full := loadPlan{mode: models.RefreshModeFull}
+	if state.Path == "" {
+		full.reason = "file not loaded before"
+		return full, nil
+	}
+	if size < state.ByteOffset {
+		full.reason = "file was truncated"
+		return full, nil
+	}
+
+	current, err := fingerprint(file, state.ByteOffset)
+	if err != nil {
+		return loadPlan{}, err
+	}
+	if current != state.Fingerprint {
+		full.reason = "file was rewritten"
+		return full, nil
+	}
+
+	header, err := decodeRecord(state.Header)
+	if err != nil {
+		full.reason = "stored header is unreadable"
+		return full, nil
+	}
+
+	return loadPlan{
+		mode:   models.RefreshModeIncremental,
+		offset: state.ByteOffset,
+		line:   state.Line,
+		header: header,
+	}, nil
+}
+
+// saveLoadState remembers that the file has been loaded up to offset
+func (s *LoaderService) saveLoadState(tx *gorm.DB, file *os.File, path string, header []string, offset int64, line int) error {
+	current, err := fingerprint(file, offset)
+	if err != nil {
+		return err
+	}
+
+	state := models.SourceFile{
+		Path:        path,
+		Header:      encodeRecord(header),
+		ByteOffset:  offset,
+		Line:        line,
+		Fingerprint: current,
+		UpdatedAt:   time.Now(),
+	}
+	if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&state).Error; err != nil {
+		return fmt.Errorf("error saving CSV load state: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sales-analytics/internal/models"
+)
+
+// openCSV writes content to a temporary file and opens it for reading
+func openCSV(t *testing.T, content string) *os.File {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "sales.csv")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("opening %s: %v", path, err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return file
+}
+
+// loadedState returns the state saved after loading content up to offset
+func loadedState(t *testing.T, content string, offset int64, line int) models.SourceFile {
+	t.Helper()
+
+	current, err := fingerprint(openCSV(t, content), offset)
+	if err != nil {
+		t.Fatalf("fingerprinting loaded file: %v", err)
+	}
+	return models.SourceFile{
+		Path:        "sales.csv",
+		Header:      encodeRecord([]string{"Order ID", "Product ID"}),
+		ByteOffset:  offset,
+		Line:        line,
+		Fingerprint: current,
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	// Large enough for the head and tail blocks not to overlap
+	large := strings.Repeat("a", 3*fingerprintBlock)
+
+	tests := []struct {
+		name     string
+		loaded   string
+		current  string
+		offset   int64
+		sameHash bool
+	}{
+		{name: "unchanged file", loaded: "header\nrow 1\n", current: "header\nrow 1\n", offset: 13, sameHash: true},
+		{name: "rows appended", loaded: "header\nrow 1\n", current: "header\nrow 1\nrow 2\n", offset: 13, sameHash: true},
+		{name: "loaded row rewritten", loaded: "header\nrow 1\n", current: "header\nrow 9\n", offset: 13, sameHash: false},
+		{name: "header rewritten", loaded: "header\nrow 1\n", current: "HEADER\nrow 1\n", offset: 13, sameHash: false},
+		{name: "nothing loaded", loaded: "", current: "header\n", offset: 0, sameHash: true},
+		{name: "large file head rewritten", loaded: large, current: "b" + large[1:], offset: int64(len(large)), sameHash: false},
+		{name: "large file tail rewritten", loaded: large, current: large[:len(large)-1] + "b", offset: int64(len(large)), sameHash: false},
+		{
+			// Only the first block and the block before the offset are hashed
+			name: "large file middle rewritten", loaded: large,
+			current: large[:fingerprintBlock+10] + "b" + large[fingerprintBlock+11:], offset: int64(len(large)), sameHash: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded, err := fingerprint(openCSV(t, tt.loaded), tt.offset)
+			if err != nil {
+				t.Fatalf("fingerprint of loaded file: %v", err)
+			}
+			current, err := fingerprint(openCSV(t, tt.current), tt.offset)
+			if err != nil {
+				t.Fatalf("fingerprint of current file: %v", err)
+			}
+			if (loaded == current) != tt.sameHash {
+				t.Errorf("fingerprints equal = %v, want %v", loaded == current, tt.sameHash)
+			}
+		})
+	}
+}
+
+func TestPlanFromState(t *testing.T) {
+	const loaded = "Order ID,Product ID\n1,P1\n2,P2\n"
+	offset := int64(len(loaded))
+
+	tests := []struct {
+		name    string
+		current string
+		state   models.SourceFile
+		want    loadPlan
+	}{
+		{
+			name:    "never loaded",
+			current: loaded,
+			want:    loadPlan{mode: models.RefreshModeFull, reason: "file not loaded before"},
+		},
+		{
+			name:    "rows appended",
+			current: loaded + "3,P3\n",
+			state:   loadedState(t, loaded, offset, 3),
+			want: loadPlan{
+				mode:   models.RefreshModeIncremental,
+				offset: offset,
+				line:   3,
+				header: []string{"Order ID", "Product ID"},
+			},
+		},
+		{
+			name:    "nothing appended",
+			current: loaded,
+			state:   loadedState(t, loaded, offset, 3),
+			want: loadPlan{
+				mode:   models.RefreshModeIncremental,
+				offset: offset,
+				line:   3,
+				header: []string{"Order ID", "Product ID"},
+			},
+		},
+		{
+			name:    "truncated",
+			current: "Order ID,Product ID\n1,P1\n",
+			state:   loadedState(t, loaded, offset, 3),
+			want:    loadPlan{mode: models.RefreshModeFull, reason: "file was truncated"},
+		},
+		{
+			name:    "rewritten",
+			current: "Order ID,Product ID\n1,P9\n2,P2\n3,P3\n",
+			state:   loadedState(t, loaded, offset, 3),
+			want:    loadPlan{mode: models.RefreshModeFull, reason: "file was rewritten"},
+		},
+		{
+			name:    "unreadable header",
+			current: loaded + "3,P3\n",
+			state: func() models.SourceFile {
+				state := loadedState(t, loaded, offset, 3)
+				state.Header = `"unbalanced`
+				return state
+			}(),
+			want: loadPlan{mode: models.RefreshModeFull, reason: "stored header is unreadable"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := planFromState(openCSV(t, tt.current), int64(len(tt.current)), tt.state)
+			if err != nil {
+				t.Fatalf("planFromState: %v", err)
+			}
+			if got.mode != tt.want.mode || got.offset != tt.want.offset || got.line != tt.want.line ||
+				got.reason != tt.want.reason || strings.Join(got.header, "|") != strings.Join(tt.want.header, "|") {
+				t.Errorf("planFromState = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanLoadForceFull(t *testing.T) {
+	// A requested full load never needs the saved state
+	loader := &LoaderService{}
+	got, err := loader.planLoad(openCSV(t, "Order ID\n1\n"), "sales.csv", 10, true)
+	if err != nil {
+		t.Fatalf("planLoad: %v", err)
+	}
+	if got.mode != models.RefreshModeFull || got.reason != "full refresh requested" {
+		t.Errorf("planLoad = %+v, want a requested full load", got)
+	}
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sales-analytics/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Tables an atomic refresh loads into before the data is merged into the live tables
+const (
+	stagingCustomers = "customers_staging"
+	stagingProducts  = "products_staging"
+	stagingPrices    = "product_prices_staging"
+	stagingOrders    = "orders_staging"
+)
+
+// Columns copied from the staging tables into the live tables
+var (
+	customerColumns = []string{"customer_id", "name", "email", "address", "region", "created_at", "updated_at"}
+	productColumns  = []string{"product_id", "name", "category", "unit_price", "created_at", "updated_at"}
+	orderColumns    = append([]string{"order_id", "created_at", "updated_at"}, reconciledOrderColumns...)
+)
+
+// orderTotals are the order count and gross sales of a set of orders
+type orderTotals struct {
+	Orders     int64
+	GrossSales float64
+}
+
+// createStagingTables creates empty staging tables shaped like the live ones, replacing any
+// left behind by an interrupted load. Staged orders keep every CSV row so duplicates can be
+// resolved the same way as in a direct load. The staging tables number their rows with their
+// own identity columns rather than the live tables' sequences, and the live IDs are assigned
+// when the rows are merged.
+func (s *LoaderService) createStagingTables() error {
+	if err := s.dropStagingTables(); err != nil {
+		return err
+	}
+
+	statements := []string{
+		fmt.Sprintf("CREATE UNLOGGED TABLE %s (LIKE customers INCLUDING ALL EXCLUDING DEFAULTS)", stagingCustomers),
+		fmt.Sprintf("CREATE UNLOGGED TABLE %s (LIKE products INCLUDING ALL EXCLUDING DEFAULTS)", stagingProducts),
+		fmt.Sprintf("CREATE UNLOGGED TABLE %s (LIKE product_prices INCLUDING ALL EXCLUDING DEFAULTS)", stagingPrices),
+		fmt.Sprintf("CREATE UNLOGGED TABLE %s (LIKE orders)", stagingOrders),
+	}
+	for _, table := range []string{stagingCustomers, stagingProducts, stagingPrices, stagingOrders} {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN id ADD GENERATED BY DEFAULT AS IDENTITY", table))
+	}
+	for _, statement := range statements {
+		if err := s.db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("error creating staging tables: %v", err)
+		}
+	}
+	return nil
+}
+
+// dropStagingTables removes the staging tables
+func (s *LoaderService) dropStagingTables() error {
+	tables := strings.Join([]string{stagingCustomers, stagingProducts, stagingPrices, stagingOrders}, ", ")
+	if err := s.db.Exec("DROP TABLE IF EXISTS " + tables).Error; err != nil {
+		return fmt.Errorf("error dropping staging tables: %v", err)
+	}
+	return nil
+}
+
+// stageBatch writes a batch to the staging tables in one transaction
+func (s *LoaderService) stageBatch(customers []models.Customer, products []models.Product, prices []models.ProductPrice, orders []models.Order) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table(stagingCustomers).Clauses(customerUpsert()).CreateInBatches(customers, s.batchSize).Error; err != nil {
+			return fmt.Errorf("error staging customers: %v", err)
+		}
+		if err := tx.Table(stagingProducts).Clauses(productUpsert()).CreateInBatches(products, s.batchSize).Error; err != nil {
+			return fmt.Errorf("error staging products: %v", err)
+		}
+		if err := tx.Table(stagingPrices).Clauses(priceUpsert()).CreateInBatches(prices, s.batchSize).Error; err != nil {
+			return fmt.Errorf("error staging product prices: %v", err)
+		}
+		if err := tx.Table(stagingOrders).CreateInBatches(orders, s.batchSize).Error; err != nil {
+			return fmt.Errorf("error staging orders: %v", err)
+		}
+		return nil
+	})
+}
+
+// validateStaging checks a staged load against figures it does not derive from: the rows read
+// and rejected from the CSV file and, for a full load that deletes missing orders, the live
+// orders it is meant to replace
+func (s *LoaderService) validateStaging(opts LoadOptions, result *loadResult) error {
+	var rows int64
+	if err := s.db.Table(stagingOrders).Count(&rows).Error; err != nil {
+		return fmt.Errorf("error counting staged orders: %v", err)
+	}
+	if err := checkStagedRows(opts.MaxDrop, result, rows); err != nil {
+		return err
+	}
+
+	// Staged orders are merged into the live ones, so only a full load that deletes the orders
+	// missing from the file can shrink them
+	if result.mode != models.RefreshModeFull || !opts.DeleteMissing {
+		return nil
+	}
+
+	var staged, live orderTotals
+	if err := s.db.Table("(?) staged", s.db.Table(stagingOrders).Select("DISTINCT ON (order_id) quantity, unit_price").Order("order_id, id")).
+		Select("COUNT(*) AS orders, COALESCE(SUM(quantity * unit_price), 0) AS gross_sales").
+		Scan(&staged).Error; err != nil {
+		return fmt.Errorf("error totalling staged orders: %v", err)
+	}
+	if err := s.db.Model(&models.Order{}).
+		Select("COUNT(*) AS orders, COALESCE(SUM(" + grossSalesExpr + "), 0) AS gross_sales").
+		Scan(&live).Error; err != nil {
+		return fmt.Errorf("error totalling live orders: %v", err)
+	}
+	return checkStagedTotals(opts.MaxDrop, staged, live)
+}
+
+// checkStagedRows refuses a load whose staged row count differs from the valid CSV rows, or that
+// rejected more than maxDrop of the rows read
+func checkStagedRows(maxDrop float64, result *loadResult, staged int64) error {
+	if valid := int64(result.rowsRead - result.rowsRejected); staged != valid {
+		return fmt.Errorf("staged %d orders but read %d valid rows", staged, valid)
+	}
+	if result.rowsRead > 0 && float64(result.rowsRejected) > maxDrop*float64(result.rowsRead) {
+		return fmt.Errorf("not applying staged data: %d of %d rows were rejected, more than the allowed %s",
+			result.rowsRejected, result.rowsRead, formatShare(maxDrop))
+	}
+	return nil
+}
+
+// checkStagedTotals refuses a load whose orders or gross sales fall short of the live ones by
+// more than maxDrop
+func checkStagedTotals(maxDrop float64, staged, live orderTotals) error {
+	if float64(staged.Orders) < (1-maxDrop)*float64(live.Orders) {
+		return fmt.Errorf("not applying staged data: the file holds %d orders but %d are live, a drop of more than the allowed %s",
+			staged.Orders, live.Orders, formatShare(maxDrop))
+	}
+	if staged.GrossSales < (1-maxDrop)*live.GrossSales {
+		return fmt.Errorf("not applying staged data: the file totals %.2f in gross sales but the live orders total %.2f, a drop of more than the allowed %s",
+			staged.GrossSales, live.GrossSales, formatShare(maxDrop))
+	}
+	return nil
+}
+
+// formatShare formats a share between 0 and 1 as a percentage
+func formatShare(share float64) string {
+	return strconv.FormatFloat(share*100, 'f', -1, 64) + "%"
+}
+
+// mergeStaged copies the rows of a staging table into a live table, resolving conflicts like a
+// direct load would, and returns the number of rows inserted or updated
+func mergeStaged(tx *gorm.DB, table string, rows *gorm.DB, columns []string, conflict clause.OnConflict) (int64, error) {
+	// The conflict clause renders its own ON CONFLICT keyword
+	sql := fmt.Sprintf("INSERT INTO %s (%s) ? ?", table, strings.Join(columns, ", "))
+	res := tx.Exec(sql, rows, conflict)
+	if res.Error != nil {
+		return 0, fmt.Errorf("error merging staged %s: %v", table, res.Error)
+	}
+	return res.RowsAffected, nil
+}
+
+// mergeStaging merges the staged data into the live tables and rebuilds the rollups in one
+// transaction, so readers see either none or all of it. Rows are inserted or updated with the
+// same rules as a direct load, and live orders are only removed when opts.DeleteMissing is set.
+// saveState records how far the CSV file was read in the same transaction.
+func (s *LoaderService) mergeStaging(opts LoadOptions, seen *seenOrders, saveState func(tx *gorm.DB) error, result *loadResult) error {
+	// Looking up staged orders by ID is only needed once loading is done
+	if err := s.db.Exec(fmt.Sprintf("CREATE INDEX ON %s (order_id)", stagingOrders)).Error; err != nil {
+		return fmt.Errorf("error indexing staged orders: %v", err)
+	}
+
+	var inserted, updated int64
+	deleted := 0
+	err := s.rollups.rebuildWithin(func() error {
+		return s.db.Transaction(func(tx *gorm.DB) error {
+			if _, err := mergeStaged(tx, "customers", tx.Table(stagingCustomers).Select(customerColumns), customerColumns, customerUpsert()); err != nil {
+				return err
+			}
+			if _, err := mergeStaged(tx, "products", tx.Table(stagingProducts).Select(productColumns), productColumns, productUpsert()); err != nil {
+				return err
+			}
+			if err := mergePriceChanges(tx, stagingPrices); err != nil {
+				return err
+			}
+
+			var unique, existing int64
+			if err := tx.Table(stagingOrders).Distinct("order_id").Count(&unique).Error; err != nil {
+				return fmt.Errorf("error counting staged orders: %v", err)
+			}
+			if err := tx.Table(stagingOrders).Distinct("order_id").
+				Where(fmt.Sprintf("EXISTS (SELECT 1 FROM orders WHERE orders.order_id = %s.order_id)", stagingOrders)).
+				Count(&existing).Error; err != nil {
+				return fmt.Errorf("error counting existing orders: %v", err)
+			}
+
+			// A direct load keeps the first row of a duplicated order, or the last one when reconciling
+			conflict, order := orderInsert(), "order_id, id"
+			if opts.Reconcile {
+				conflict, order = orderUpsert(), "order_id, id DESC"
+			}
+			rows := tx.Table(stagingOrders).
+				Select("DISTINCT ON (order_id) " + strings.Join(orderColumns, ", ")).
+				Order(order)
+			affected, err := mergeStaged(tx, "orders", rows, orderColumns, conflict)
+			if err != nil {
+				return err
+			}
+			inserted, updated = reconcileCounts(unique, existing, affected)
+
+			if seen != nil {
+				if deleted, err = s.deleteMissingOrders(tx, seen); err != nil {
+					return err
+				}
+			}
+
+			if err := saveState(tx); err != nil {
+				return err
+			}
+			return rebuildRollups(tx)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	result.rowsInserted = int(inserted)
+	result.rowsUpdated = int(updated)
+	result.rowsSkipped = result.rowsRead - result.rowsRejected - int(inserted) - int(updated)
+	result.rowsDeleted = deleted
+	return nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckStagedRows(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxDrop  float64
+		read     int
+		rejected int
+		staged   int64
+		wantErr  string
+	}{
+		{name: "every row staged", maxDrop: 0.2, read: 100, staged: 100},
+		{name: "rejects within the limit", maxDrop: 0.2, read: 100, rejected: 20, staged: 80},
+		{name: "nothing read", maxDrop: 0.2},
+		{name: "rows missing from staging", maxDrop: 0.2, read: 100, rejected: 5, staged: 90, wantErr: "staged 90 orders but read 95 valid rows"},
+		{name: "too many rejects", maxDrop: 0.2, read: 100, rejected: 21, staged: 79, wantErr: "21 of 100 rows were rejected, more than the allowed 20%"},
+		{name: "no rejects allowed", maxDrop: 0, read: 100, rejected: 1, staged: 99, wantErr: "more than the allowed 0%"},
+		{name: "check disabled", maxDrop: 1, read: 100, rejected: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &loadResult{rowsRead: tt.read, rowsRejected: tt.rejected}
+			checkError(t, checkStagedRows(tt.maxDrop, result, tt.staged), tt.wantErr)
+		})
+	}
+}
+
+func TestCheckStagedTotals(t *testing.T) {
+	live := orderTotals{Orders: 1000, GrossSales: 50000}
+
+	tests := []struct {
+		name    string
+		maxDrop float64
+		staged  orderTotals
+		live    orderTotals
+		wantErr string
+	}{
+		{name: "same as live", maxDrop: 0.2, staged: live, live: live},
+		{name: "grown", maxDrop: 0.2, staged: orderTotals{Orders: 1100, GrossSales: 60000}, live: live},
+		{name: "drop at the limit", maxDrop: 0.2, staged: orderTotals{Orders: 800, GrossSales: 40000}, live: live},
+		{name: "nothing live", maxDrop: 0, staged: orderTotals{}, live: orderTotals{}},
+		{
+			name: "orders dropped", maxDrop: 0.2, staged: orderTotals{Orders: 799, GrossSales: 50000}, live: live,
+			wantErr: "the file holds 799 orders but 1000 are live",
+		},
+		{
+			name: "gross sales dropped", maxDrop: 0.2, staged: orderTotals{Orders: 1000, GrossSales: 39999}, live: live,
+			wantErr: "the file totals 39999.00 in gross sales but the live orders total 50000.00",
+		},
+		{name: "check disabled", maxDrop: 1, staged: orderTotals{}, live: live},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkError(t, checkStagedTotals(tt.maxDrop, tt.staged, tt.live), tt.wantErr)
+		})
+	}
+}
+
+func TestFormatShare(t *testing.T) {
+	for share, want := range map[float64]string{0: "0%", 0.2: "20%", 0.125: "12.5%", 1: "100%"} {
+		if got := formatShare(share); got != want {
+			t.Errorf("formatShare(%v) = %s, want %s", share, got, want)
+		}
+	}
+}
+
+// checkError fails the test unless err contains want, or is nil when want is empty
+func checkError(t *testing.T, err error, want string) {
+	t.Helper()
+
+	if want == "" {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("error = %v, want one containing %q", err, want)
+	}
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"sales-analytics/internal/models"
+)
+
+// Supported time series granularities
+const (
+	GranularityDay     = "day"
+	GranularityWeek    = "week"
+	GranularityMonth   = "month"
+	GranularityQuarter = "quarter"
+	GranularityYear    = "year"
+)
+
+// Supported time series groupings
+const (
+	GroupByProduct  = "product"
+	GroupByCategory = "category"
+	GroupByRegion   = "region"
+)
+
+// MaxPeriods is the largest number of buckets a time series or a monthly cross-tab may span
+const MaxPeriods = 1000
+
+// MaxSeries is the largest number of groups a time series returns separately; the rest are
+// summed into an "other" series
+const MaxSeries = 100
+
+// seriesGroup holds the SQL columns identifying and labelling a series
+type seriesGroup struct {
+	keyColumn   string
+	labelColumn string
+}
+
+var seriesGroups = map[string]seriesGroup{
+	GroupByProduct:  {keyColumn: "products.product_id", labelColumn: "products.name"},
+	GroupByCategory: {keyColumn: "products.category", labelColumn: "products.category"},
+	GroupByRegion:   {keyColumn: "customers.region", labelColumn: "customers.region"},
+}
+
+// IsValidGranularity reports whether g is a supported time series granularity
+func IsValidGranularity(g string) bool {
+	switch g {
+	case GranularityDay, GranularityWeek, GranularityMonth, GranularityQuarter, GranularityYear:
+		return true
+	}
+	return false
+}
+
+// IsValidTimeSeriesGroup reports whether groupBy is a supported time series grouping
+func IsValidTimeSeriesGroup(groupBy string) bool {
+	_, ok := seriesGroups[groupBy]
+	return ok
+}
+
+// GetRevenueTimeSeries returns revenue bucketed by granularity with empty buckets zero-filled.
+// When groupBy is set, one series is returned for each of the limit products, categories or
+// regions with the most revenue, and the revenue of the rest is summed into an "other" series.
+func (s *RevenueService) GetRevenueTimeSeries(filter models.RevenueFilter, granularity, groupBy string, limit int) (*models.RevenueTimeSeries, error) {
+	if !IsValidGranularity(granularity) {
+		return nil, fmt.Errorf("unsupported granularity '%s'", granularity)
+	}
+
+	// Granularity is whitelisted above, so it is safe to inline into the query
+	periodExpr := fmt.Sprintf("date_trunc('%s', orders.date_of_sale AT TIME ZONE 'UTC')", granularity)
+	keyColumn, labelColumn := "''", "''"
+	if groupBy != "" {
+		group, ok := seriesGroups[groupBy]
+		if !ok {
+			return nil, fmt.Errorf("unsupported group_by '%s'", groupBy)
+		}
+		keyColumn, labelColumn = group.keyColumn, group.labelColumn
+	}
+
+	var rows []struct {
+		Period      time.Time
+		Other       bool
+		SeriesKey   string
+		SeriesLabel string
+		Revenue     float64
+	}
+
+	grouped := filteredOrders(s.db, filter).
+		Select(periodExpr + " as period, " + keyColumn + " as series_key, " + labelColumn + " as series_label, COALESCE(SUM(" + revenueExpr(filter.Revenue) + "), 0) as revenue").
+		Group("1, 2, 3")
+
+	var err error
+	if groupBy == "" {
+		err = s.db.Table("(?) grouped", grouped).
+			Select("period, FALSE as other, series_key, series_label, revenue").
+			Order("period").
+			Scan(&rows).Error
+	} else {
+		// Rank the series by total revenue, then fold those beyond the limit into one
+		totals := s.db.Table("(?) grouped", grouped).
+			Select("grouped.*, SUM(revenue) OVER (PARTITION BY series_key) as series_total")
+		ranked := s.db.Table("(?) totals", totals).
+			Select("totals.*, DENSE_RANK() OVER (ORDER BY series_total DESC, series_key) as series_rank")
+		err = s.db.Table("(?) ranked", ranked).
+			Select("period, series_rank > ? as other, "+
+				"CASE WHEN series_rank > ? THEN '' ELSE series_key END as series_key, "+
+				"CASE WHEN series_rank > ? THEN '' ELSE series_label END as series_label, "+
+				"SUM(revenue) as revenue", limit, limit, limit).
+			Group("1, 2, 3, 4").
+			Order("period").
+			Scan(&rows).Error
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error querying revenue time series: %v", err)
+	}
+
+	periods := periodBuckets(filter.StartDate, filter.EndDate, granularity)
+	seriesByKey := make(map[string]*models.RevenueSeries)
+	revenueByKey := make(map[string]map[time.Time]float64)
+	var order []string
+
+	for _, row := range rows {
+		// The other series cannot clash with a group, whatever its key
+		key := "key:" + row.SeriesKey
+		if row.Other {
+			key = "other"
+		}
+		series, ok := seriesByKey[key]
+		if !ok {
+			series = &models.RevenueSeries{Key: row.SeriesKey, Label: row.SeriesLabel, Other: row.Other}
+			if row.Other {
+				series.Label = "Other"
+			}
+			seriesByKey[key] = series
+			revenueByKey[key] = make(map[time.Time]float64)
+			order = append(order, key)
+		}
+		revenueByKey[key][row.Period.UTC()] += row.Revenue
+		series.Total += row.Revenue
+	}
+
+	// Without grouping there is always exactly one series, even when there are no orders
+	if groupBy == "" && len(order) == 0 {
+		seriesByKey[""] = &models.RevenueSeries{}
+		revenueByKey[""] = make(map[time.Time]float64)
+		order = append(order, "")
+	}
+
+	result := &models.RevenueTimeSeries{
+		Granularity:       granularity,
+		GroupBy:           groupBy,
+		RevenueDefinition: filter.Revenue,
+		Series:            make([]models.RevenueSeries, 0, len(order)),
+	}
+	if groupBy != "" {
+		result.Limit = limit
+	}
+	for _, key := range order {
+		series := seriesByKey[key]
+		series.Points = make([]models.RevenuePoint, 0, len(periods))
+		for _, period := range periods {
+			series.Points = append(series.Points, models.RevenuePoint{
+				Period:  period,
+				Revenue: revenueByKey[key][period],
+			})
+		}
+		result.Series = append(result.Series, *series)
+	}
+
+	// Largest series first, with the other series last
+	sort.SliceStable(result.Series, func(i, j int) bool {
+		if result.Series[i].Other != result.Series[j].Other {
+			return result.Series[j].Other
+		}
+		return result.Series[i].Total > result.Series[j].Total
+	})
+
+	return result, nil
+}
+
+// periodBuckets lists the start of every bucket between startDate and endDate, matching
+// PostgreSQL's date_trunc (weeks start on Monday)
+func periodBuckets(startDate, endDate time.Time, granularity string) []time.Time {
+	var buckets []time.Time
+	for period := truncatePeriod(startDate, granularity); !period.After(endDate); period = nextPeriod(period, granularity) {
+		buckets = append(buckets, period)
+	}
+	return buckets
+}
+
+// CountPeriods returns the number of buckets between startDate and endDate without listing them
+func CountPeriods(startDate, endDate time.Time, granularity string) int {
+	start := truncatePeriod(startDate, granularity)
+	end := truncatePeriod(endDate, granularity)
+	if end.Before(start) {
+		return 0
+	}
+
+	months := (end.Year()-start.Year())*12 + int(end.Month()) - int(start.Month())
+	switch granularity {
+	case GranularityWeek:
+		return int(end.Sub(start).Hours()/24)/7 + 1
+	case GranularityMonth:
+		return months + 1
+	case GranularityQuarter:
+		return months/3 + 1
+	case GranularityYear:
+		return end.Year() - start.Year() + 1
+	default:
+		return int(end.Sub(start).Hours()/24) + 1
+	}
+}
+
+// truncatePeriod returns the start of the bucket containing t
+func truncatePeriod(t time.Time, granularity string) time.Time {
+	t = t.UTC()
+	year, month, day := t.Date()
+
+	switch granularity {
+	case GranularityWeek:
+		offset := (int(t.Weekday()) + 6) % 7 // days since Monday
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, time.UTC)
+	case GranularityMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	case GranularityQuarter:
+		return time.Date(year, ((month-1)/3)*3+1, 1, 0, 0, 0, 0, time.UTC)
+	case GranularityYear:
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// nextPeriod returns the start of the bucket following period
+func nextPeriod(period time.Time, granularity string) time.Time {
+	switch granularity {
+	case GranularityWeek:
+		return period.AddDate(0, 0, 7)
+	case GranularityMonth:
+		return period.AddDate(0, 1, 0)
+	case GranularityQuarter:
+		return period.AddDate(0, 3, 0)
+	case GranularityYear:
+		return period.AddDate(1, 0, 0)
+	default:
+		return period.AddDate(0, 0, 1)
+	}
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"sales-analytics/internal/models"
+)
+
+// csvRow is a CSV record that passed validation, split into the entities it describes
+type csvRow struct {
+	customer models.Customer
+	product  models.Product
+	order    models.Order
+}
+
+// RowValidationError lists every problem found in a single CSV record
+type RowValidationError struct {
+	Problems []string
+}
+
+func (e *RowValidationError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+// validateRecord checks a raw CSV record and converts it into a csvRow
+func validateRecord(record []string, cols columnIndex) (*csvRow, error) {
+	if len(record) != cols.width {
+		return nil, &RowValidationError{Problems: []string{
+			fmt.Sprintf("expected %d columns, got %d", cols.width, len(record)),
+		}}
+	}
+
+	var problems []string
+	required := func(field, name string) string {
+		value := cols.value(record, field)
+		if value == "" {
+			problems = append(problems, fmt.Sprintf("%s is required", name))
+		}
+		return value
+	}
+	nonNegativeFloat := func(field, name string) float64 {
+		if _, ok := cols.positions[field]; !ok {
+			return 0 // optional column not present in this file
+		}
+		value, err := strconv.ParseFloat(cols.value(record, field), 64)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("invalid %s '%s'", name, cols.raw(record, field)))
+			return 0
+		}
+		if value < 0 {
+			problems = append(problems, fmt.Sprintf("%s cannot be negative", name))
+		}
+		return value
+	}
+
+	orderID := required(FieldOrderID, "order ID")
+	productID := required(FieldProductID, "product ID")
+	customerID := required(FieldCustomerID, "customer ID")
+
+	date, err := time.Parse("2006-01-02", cols.value(record, FieldDateOfSale))
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("invalid date of sale '%s', expected YYYY-MM-DD", cols.raw(record, FieldDateOfSale)))
+	}
+
+	quantity, err := strconv.Atoi(cols.value(record, FieldQuantity))
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("invalid quantity '%s'", cols.raw(record, FieldQuantity)))
+	} else if quantity < 0 {
+		problems = append(problems, "quantity cannot be negative")
+	}
+
+	unitPrice := nonNegativeFloat(FieldUnitPrice, "unit price")
+	discount := nonNegativeFloat(FieldDiscount, "discount")
+	shippingCost := nonNegativeFloat(FieldShippingCost, "shipping cost")
+
+	email := cols.value(record, FieldCustomerEmail)
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		problems = append(problems, fmt.Sprintf("invalid customer email '%s'", cols.raw(record, FieldCustomerEmail)))
+	}
+
+	if len(problems) > 0 {
+		return nil, &RowValidationError{Problems: problems}
+	}
+
+	return &csvRow{
+		customer: models.Customer{
+			CustomerID: customerID,
+			Name:       cols.value(record, FieldCustomerName),
+			Email:      email,
+			Address:    cols.value(record, FieldCustomerAddress),
+			Region:     cols.value(record, FieldRegion),
+		},
+		product: models.Product{
+			ProductID: productID,
+			Name:      cols.value(record, FieldProductName),
+			Category:  cols.value(record, FieldCategory),
+			UnitPrice: unitPrice,
+		},
+		order: models.Order{
+			OrderID:       orderID,
+			ProductID:     productID,
+			CustomerID:    customerID,
+			DateOfSale:    date,
+			Quantity:      quantity,
+			UnitPrice:     unitPrice,
+			Discount:      discount,
+			ShippingCost:  shippingCost,
+			PaymentMethod: cols.value(record, FieldPaymentMethod),
+		},
+	}, nil
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+// standardColumns indexes the default headers in a fixed order
+func standardColumns(t *testing.T) (columnIndex, []string) {
+	t.Helper()
+
+	header := []string{
+		"Order ID", "Product ID", "Customer ID", "Product Name", "Category", "Region", "Date of Sale",
+		"Quantity Sold", "Unit Price", "Discount", "Shipping Cost", "Payment Method",
+		"Customer Name", "Customer Email", "Customer Address",
+	}
+	mapping, err := NewColumnMapping(nil)
+	if err != nil {
+		t.Fatalf("NewColumnMapping: %v", err)
+	}
+	cols, _, err := mapping.resolve(header)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	return cols, header
+}
+
+// validRecord returns a record of the standard columns with the given header values replaced
+func validRecord(header []string, replace map[string]string) []string {
+	values := map[string]string{
+		"Order ID": "1001", "Product ID": "P123", "Customer ID": "C456", "Product Name": "UltraBoost",
+		"Category": "Shoes", "Region": "North America", "Date of Sale": "2024-01-01",
+		"Quantity Sold": "2", "Unit Price": "180.00", "Discount": "0.1", "Shipping Cost": "10.00",
+		"Payment Method": "Credit Card", "Customer Name": "John Doe",
+		"Customer Email": "johndoe@example.com", "Customer Address": "123 Main St",
+	}
+	for name, value := range replace {
+		values[name] = value
+	}
+
+	record := make([]string, len(header))
+	for i, name := range header {
+		record[i] = values[name]
+	}
+	return record
+}
+
+func TestValidateRecord(t *testing.T) {
+	cols, header := standardColumns(t)
+
+	tests := []struct {
+		name     string
+		record   []string
+		problems []string
+	}{
+		{name: "valid row", record: validRecord(header, nil)},
+		{name: "values are trimmed", record: validRecord(header, map[string]string{"Quantity Sold": " 2 ", "Order ID": " 1001"})},
+		{name: "optional values empty", record: validRecord(header, map[string]string{"Payment Method": "", "Customer Name": ""})},
+		{
+			name:     "wrong column count",
+			record:   []string{"1001", "P123"},
+			problems: []string{"expected 15 columns, got 2"},
+		},
+		{
+			name:     "missing IDs",
+			record:   validRecord(header, map[string]string{"Order ID": "", "Product ID": " ", "Customer ID": ""}),
+			problems: []string{"order ID is required", "product ID is required", "customer ID is required"},
+		},
+		{
+			name:     "invalid date",
+			record:   validRecord(header, map[string]string{"Date of Sale": "01/02/2024"}),
+			problems: []string{"invalid date of sale '01/02/2024', expected YYYY-MM-DD"},
+		},
+		{
+			name:     "invalid quantity",
+			record:   validRecord(header, map[string]string{"Quantity Sold": "two"}),
+			problems: []string{"invalid quantity 'two'"},
+		},
+		{
+			name:     "negative quantity",
+			record:   validRecord(header, map[string]string{"Quantity Sold": "-1"}),
+			problems: []string{"quantity cannot be negative"},
+		},
+		{
+			name:     "invalid and negative amounts",
+			record:   validRecord(header, map[string]string{"Unit Price": "abc", "Discount": "-0.5", "Shipping Cost": ""}),
+			problems: []string{"invalid unit price 'abc'", "discount cannot be negative", "invalid shipping cost ''"},
+		},
+		{
+			name:     "invalid email",
+			record:   validRecord(header, map[string]string{"Customer Email": "John <johndoe@example.com>"}),
+			problems: []string{"invalid customer email 'John <johndoe@example.com>'"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, err := validateRecord(tt.record, cols)
+			if tt.problems == nil {
+				if err != nil {
+					t.Fatalf("validateRecord returned %v, want no error", err)
+				}
+				if row.order.OrderID != "1001" || row.order.Quantity != 2 || row.product.UnitPrice != 180 {
+					t.Errorf("validateRecord = %+v, want the parsed order", row.order)
+				}
+				return
+			}
+
+			validationErr, ok := err.(*RowValidationError)
+			if !ok {
+				t.Fatalf("validateRecord returned %v, want a *RowValidationError", err)
+			}
+			if !reflect.DeepEqual(validationErr.Problems, tt.problems) {
+				t.Errorf("problems = %q, want %q", validationErr.Problems, tt.problems)
+			}
+		})
+	}
+}
+
+func TestValidateRecordOptionalColumnAbsent(t *testing.T) {
+	// Files without the optional amount columns load them as zero
+	mapping, err := NewColumnMapping(nil)
+	if err != nil {
+		t.Fatalf("NewColumnMapping: %v", err)
+	}
+	header := []string{
+		"Order ID", "Product ID", "Customer ID", "Product Name", "Category", "Region", "Date of Sale",
+		"Quantity Sold", "Unit Price", "Customer Email",
+	}
+	cols, _, err := mapping.resolve(header)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+
+	row, err := validateRecord(validRecord(header, nil), cols)
+	if err != nil {
+		t.Fatalf("validateRecord: %v", err)
+	}
+	if row.order.Discount != 0 || row.order.ShippingCost != 0 || row.order.PaymentMethod != "" {
+		t.Errorf("validateRecord = %+v, want zero optional fields", row.order)
+	}
+}